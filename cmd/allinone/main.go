@@ -0,0 +1,180 @@
+// Command allinone runs the HTTP server and the batch worker in a single
+// process, connected by the in-memory broker unless another BROKER is
+// configured. It suits small deployments such as edge sites.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"play.ground/generic-data-collector/initializers"
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/routes"
+)
+
+// settings holds the settings of both the server and the worker, which share
+// a single config file.
+type settings struct {
+	Server initializers.ServerConfig `mapstructure:",squash"`
+	Worker initializers.WorkerConfig `mapstructure:",squash"`
+}
+
+func main() {
+	configPath := flag.String("config", "", "config file to read (default $"+initializers.ConfigFileEnv+" or ./config/$GO_ENV.yml)")
+	printConfig := flag.Bool("print-config", false, "print the effective config, with secrets redacted, and exit")
+	flag.Parse()
+
+	config, err := initializers.NewConfig(initializers.ConfigPath(*configPath))
+	if err != nil {
+		log.Fatal(err)
+	}
+	serverSettings, serverErr := initializers.LoadServerConfig(config)
+	workerSettings, workerErr := initializers.LoadWorkerConfig(config)
+	if err := errors.Join(serverErr, workerErr); err != nil {
+		log.Fatalf("Invalid configuration in %s:\n%v", config.ConfigFileUsed(), err)
+	}
+	if *printConfig {
+		if err := initializers.WriteRedacted(os.Stdout, settings{Server: serverSettings, Worker: workerSettings}); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// The server registry comes first, so its settings create the memory broker.
+	server, err := registries.NewServerAppRegistry(config, serverSettings)
+	if err != nil {
+		log.Fatalf("Failed to initialize server registry: %v", err)
+	}
+	worker, err := registries.NewWorkerAppRegistry(config, workerSettings)
+	if err != nil {
+		log.Fatalf("Failed to initialize worker registry: %v", err)
+	}
+	logger := server.Logger
+	if err := registries.WatchConfig(config, server, worker); err != nil {
+		logger.Warn("Failed to watch the config file, changes require a restart", "error", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// The worker subscribes before the server accepts records, since the
+	// memory broker drops messages nobody has subscribed to.
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	defer stopWorker()
+	processorDone := make(chan error, 1)
+	go func() {
+		processorDone <- worker.BatchProcessor.Start(workerCtx, worker.Topics...)
+	}()
+	select {
+	case <-worker.BatchProcessor.Subscribed():
+	case err := <-processorDone:
+		logger.Error("Batch processor failed to start", "error", err)
+		os.Exit(1)
+	}
+
+	admin := routes.NewWorkerAdmin(worker)
+	go func() {
+		logger.Info("Starting admin HTTP server", "addr", admin.Addr)
+		if err := admin.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Admin HTTP server failed", "error", err)
+		}
+	}()
+
+	httpServer, err := routes.NewServer(server)
+	if err != nil {
+		logger.Error("Failed to create HTTP server", "error", err)
+		os.Exit(1)
+	}
+	httpErr := make(chan error, 1)
+	go func() {
+		logger.Info("Starting HTTP server", "addr", httpServer.Addr)
+		httpErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-httpErr:
+		logger.Error("Failed to start server", "error", err)
+		os.Exit(1)
+	case err := <-processorDone:
+		logger.Error("Batch processor exited", "error", err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutdown signal received, draining", "timeout", server.ShutdownTimeout)
+	shutdown(server, worker, httpServer, admin, stopWorker, processorDone)
+	logger.Info("Shutdown complete")
+}
+
+// shutdown stops accepting requests, waits for the publishes, and lets the
+// batch processor write the records still buffered before closing the sink.
+// A single deadline of ShutdownTimeout covers the whole sequence.
+func shutdown(server *registries.ServerAppRegistry, worker *registries.WorkerAppRegistry, httpServer, admin *http.Server, stopWorker context.CancelFunc, processorDone <-chan error) {
+	ctx, cancel := context.WithTimeout(context.Background(), server.ShutdownTimeout)
+	defer cancel()
+	logger := server.Logger
+
+	if err := httpServer.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Warn("Error shutting down HTTP server", "error", err)
+	}
+	if err := server.Publishes.Wait(ctx); err != nil {
+		logger.Warn("Gave up waiting for publishes", "pending", server.Publishes.Len(), "error", err)
+	}
+	// The memory broker keeps nothing, so the processor drains its
+	// subscriptions and returns once it has written what they buffered.
+	// Other brokers keep their messages, so the processor is stopped right
+	// away.
+	if err := server.Producer.Close(); err != nil {
+		logger.Warn("Error closing producer", "error", err)
+	}
+	if worker.Settings.Broker.Type == initializers.BrokerMemory {
+		if err := worker.BatchProcessor.Drain(); err != nil {
+			logger.Warn("Error draining batch processor", "error", err)
+			stopWorker()
+		}
+	} else {
+		stopWorker()
+	}
+	select {
+	case <-processorDone:
+	case <-ctx.Done():
+		logger.Warn("Gave up waiting for the batch processor to drain")
+		stopWorker()
+		<-processorDone
+	}
+	logger.Info("Batch processor stats", "stats", worker.BatchProcessor.Stats())
+
+	if err := worker.Consumer.Close(); err != nil {
+		logger.Warn("Error closing consumer", "error", err)
+	}
+	if err := worker.Sink.Close(); err != nil {
+		logger.Warn("Error closing sink", "error", err)
+	}
+	if worker.DeadLetter != nil {
+		if err := worker.DeadLetter.Close(); err != nil {
+			logger.Warn("Error closing dead-letter producer", "error", err)
+		}
+	}
+	if err := admin.Shutdown(ctx); err != nil {
+		logger.Warn("Error shutting down admin HTTP server", "error", err)
+	}
+	// Both registries share the embedded NATS server.
+	if server.EmbeddedNATS != nil {
+		server.EmbeddedNATS.Shutdown()
+	}
+	for _, provider := range []*sdktrace.TracerProvider{server.TracerProvider, worker.TracerProvider} {
+		if provider == nil {
+			continue
+		}
+		if err := provider.Shutdown(ctx); err != nil {
+			logger.Warn("Error flushing spans", "error", err)
+		}
+	}
+}
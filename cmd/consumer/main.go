@@ -2,44 +2,110 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
+	"play.ground/generic-data-collector/initializers"
 	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/routes"
+	"play.ground/generic-data-collector/internal/services"
 )
 
-const (
-	TopicName = "metrics"
-)
+// exitTimeout bounds how long stopping the admin server and flushing spans may take.
+const exitTimeout = 5 * time.Second
 
 func main() {
-	registry, err := registries.NewWorkerAppRegistry()
+	configPath := flag.String("config", "", "config file to read (default $"+initializers.ConfigFileEnv+" or ./config/$GO_ENV.yml)")
+	printConfig := flag.Bool("print-config", false, "print the effective config, with secrets redacted, and exit")
+	flag.Parse()
+
+	config, err := initializers.NewConfig(initializers.ConfigPath(*configPath))
+	if err != nil {
+		log.Fatal(err)
+	}
+	settings, err := initializers.LoadWorkerConfig(config)
+	if err != nil {
+		log.Fatalf("Invalid configuration in %s:\n%v", config.ConfigFileUsed(), err)
+	}
+	if *printConfig {
+		if err := initializers.WriteRedacted(os.Stdout, settings); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	registry, err := registries.NewWorkerAppRegistry(config, settings)
 	if err != nil {
 		log.Fatalf("Failed to create registry: %v", err)
 	}
 
+	logger := registry.Logger
+	if err := registry.WatchConfig(); err != nil {
+		logger.Warn("Failed to watch the config file, changes require a restart", "error", err)
+	}
+
+	admin := routes.NewWorkerAdmin(registry)
+	go func() {
+		logger.Info("Starting admin HTTP server", "addr", admin.Addr)
+		if err := admin.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Admin HTTP server failed", "error", err)
+		}
+	}()
+
 	runner := func() error {
-		if registry.Config.GetBool("RUN_WITH_BATCHES") {
+		if registry.Settings.RunWithBatches {
 			return runWithBatches(registry)
 		}
 		return run(registry)
 	}
 
-	if err := runner(); err != nil {
-		log.Fatalf("Application failed: %v", err)
+	err = runner()
+
+	ctx, cancel := context.WithTimeout(context.Background(), exitTimeout)
+	defer cancel()
+	if err := admin.Shutdown(ctx); err != nil {
+		logger.Warn("Error shutting down admin HTTP server", "error", err)
+	}
+	if registry.TracerProvider != nil {
+		if err := registry.TracerProvider.Shutdown(ctx); err != nil {
+			logger.Warn("Error flushing spans", "error", err)
+		}
+	}
+	if registry.EmbeddedNATS != nil {
+		registry.EmbeddedNATS.Shutdown()
+	}
+
+	if err != nil {
+		logger.Error("Application failed", "error", err)
+		os.Exit(1)
 	}
 }
 
 // run contains all the application logic and returns an error if startup fails
 func run(registry *registries.WorkerAppRegistry) error {
-	// Ensure consumer is closed on exit
+	logger := registry.Logger
+
+	// Ensure the consumer, the sink and the dead-letter producer are closed on exit
 	defer func() {
 		if err := registry.Consumer.Close(); err != nil {
-			log.Printf("Error closing consumer: %v", err)
+			logger.Warn("Error closing consumer", "error", err)
+		}
+		if err := registry.Sink.Close(); err != nil {
+			logger.Warn("Error closing sink", "error", err)
+		}
+		if registry.DeadLetter != nil {
+			if err := registry.DeadLetter.Close(); err != nil {
+				logger.Warn("Error closing dead-letter producer", "error", err)
+			}
 		}
 	}()
 
@@ -47,25 +113,26 @@ func run(registry *registries.WorkerAppRegistry) error {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, os.Interrupt)
 	defer stop()
 
-	log.Println("Consumer worker starting...")
+	logger.Info("Consumer worker starting")
 
 	// Start consuming
-	if err := consumeMessages(ctx, registry, TopicName); err != nil {
+	if err := consumeMessages(ctx, registry, registry.Topics); err != nil {
 		return fmt.Errorf("message consumption failed: %w", err)
 	}
 
-	log.Println("Graceful shutdown complete.")
+	logger.Info("Graceful shutdown complete")
 	return nil
 }
 
-// consumeMessages starts consuming from the topic and returns when context is cancelled
-func consumeMessages(ctx context.Context, registry *registries.WorkerAppRegistry, topic string) error {
-	msgCh, err := registry.Consumer.Subscribe(topic)
+// consumeMessages starts consuming from the topics and returns when context is cancelled
+func consumeMessages(ctx context.Context, registry *registries.WorkerAppRegistry, topics []string) error {
+	msgCh, err := services.SubscribeAll(ctx, registry.Consumer, registry.QueueGroup, topics)
 	if err != nil {
-		return fmt.Errorf("failed to subscribe to topic %q: %s", err, topic)
+		return fmt.Errorf("failed to subscribe to topics %q: %s", topics, err)
 	}
 
-	log.Printf("Consumer worker started. Subscribed to topic: %s", topic)
+	logger := registry.Logger
+	logger.Info("Consumer worker started", "topics", strings.Join(topics, ", "))
 
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -75,60 +142,87 @@ func consumeMessages(ctx context.Context, registry *registries.WorkerAppRegistry
 		for {
 			select {
 			case <-ctx.Done():
-				log.Println("Shutdown signal received, stopping message consumption...")
+				logger.Info("Shutdown signal received, stopping message consumption")
 				return
 			case msg, ok := <-msgCh:
 				if !ok {
-					log.Println("Message channel closed by publisher.")
+					logger.Info("Message channel closed by publisher")
 					return
 				}
 				// Process message (you can add error handling / retry logic here)
-				fmt.Printf("Received message: %v\n", string(msg.Data()))
+				// Payloads may hold sensitive data, so they are only logged at debug level.
+				logger.Debug("Received message", "subject", msg.Subject(), "data", string(msg.Data()))
+				if err := msg.Ack(); err != nil {
+					logger.Warn("Failed to ack message", "subject", msg.Subject(), "error", err)
+				}
 			}
 		}
 	}()
 
 	// Wait for either context cancellation or goroutine exit
 	wg.Wait()
-	log.Println("Message consumer stopped.")
+	logger.Info("Message consumer stopped")
 	return nil
 }
 
 func runWithBatches(registry *registries.WorkerAppRegistry) error {
+	logger := registry.Logger
+
 	// Use context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
-	var wg sync.WaitGroup
+	defer cancel()
 
 	// Start the batch processor in a goroutine
-	wg.Add(1)
+	processorDone := make(chan error, 1)
 	go func() {
-		defer wg.Done()
-		if err := registry.BatchProcessor.Start(ctx, TopicName); err != nil {
-			log.Printf("Batch processor exited with error: %v", err)
-		} else {
-			log.Println("Batch processor exited gracefully.")
-		}
+		processorDone <- registry.BatchProcessor.Start(ctx, registry.Topics...)
 	}()
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal, or for the processor to fail
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, os.Interrupt)
 
-	<-quit
-	log.Println("Shutting down consumer worker...")
+	var failed error
+	select {
+	case <-quit:
+		logger.Info("Shutting down consumer worker")
 
-	// Signal the batch processor to stop
-	cancel()
+		// Signal the batch processor to stop
+		cancel()
 
-	// Wait for the processor to finish processing its final batch
-	log.Println("Waiting for batch processor to shut down...")
-	wg.Wait()
+		// Wait for the processor to finish processing its final batch
+		logger.Info("Waiting for batch processor to shut down")
+		if err := <-processorDone; err != nil {
+			logger.Error("Batch processor exited with error", "error", err)
+		} else {
+			logger.Info("Batch processor exited gracefully")
+		}
+	case err := <-processorDone:
+		// The processor only returns on its own if it fails to subscribe or
+		// its subscriptions are closed, so the worker would consume nothing.
+		if err == nil {
+			err = errors.New("subscriptions closed")
+		}
+		failed = fmt.Errorf("batch processor stopped: %w", err)
+	}
+	logger.Info("Batch processor stats", "stats", registry.BatchProcessor.Stats())
 
-	// Now, safely close the consumer connection
+	// Now, safely close the consumer connection and the sink
 	if err := registry.Consumer.Close(); err != nil {
-		log.Printf("Error closing consumer: %v", err)
+		logger.Warn("Error closing consumer", "error", err)
+	}
+	if err := registry.Sink.Close(); err != nil {
+		logger.Warn("Error closing sink", "error", err)
+	}
+	if registry.DeadLetter != nil {
+		if err := registry.DeadLetter.Close(); err != nil {
+			logger.Warn("Error closing dead-letter producer", "error", err)
+		}
 	}
 
-	log.Println("Shutdown complete.")
+	if failed != nil {
+		return failed
+	}
+	logger.Info("Shutdown complete")
 	return nil
 }
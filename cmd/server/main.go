@@ -1,17 +1,113 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"play.ground/generic-data-collector/initializers"
 	"play.ground/generic-data-collector/internal/registries"
 	"play.ground/generic-data-collector/internal/routes"
 )
 
 func main() {
-	registry, err := registries.NewServerAppRegistry()
+	configPath := flag.String("config", "", "config file to read (default $"+initializers.ConfigFileEnv+" or ./config/$GO_ENV.yml)")
+	printConfig := flag.Bool("print-config", false, "print the effective config, with secrets redacted, and exit")
+	flag.Parse()
+
+	config, err := initializers.NewConfig(initializers.ConfigPath(*configPath))
+	if err != nil {
+		log.Fatal(err)
+	}
+	settings, err := initializers.LoadServerConfig(config)
+	if err != nil {
+		log.Fatalf("Invalid configuration in %s:\n%v", config.ConfigFileUsed(), err)
+	}
+	if *printConfig {
+		if err := initializers.WriteRedacted(os.Stdout, settings); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	registry, err := registries.NewServerAppRegistry(config, settings)
 	if err != nil {
 		log.Fatalf("Failed to initialize server registry: %v", err)
 	}
 
-	routes.Run(registry)
+	logger := registry.Logger
+
+	if err := registry.WatchConfig(); err != nil {
+		logger.Warn("Failed to watch the config file, changes require a restart", "error", err)
+	}
+	go reloadConfigOnSIGHUP(registry)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	server, err := routes.NewServer(registry)
+	if err != nil {
+		logger.Error("Failed to create HTTP server", "error", err)
+		os.Exit(1)
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("Starting HTTP server", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		logger.Error("Failed to start server", "error", err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutdown signal received, draining", "timeout", registry.ShutdownTimeout)
+	shutdown(registry, server)
+	logger.Info("Shutdown complete")
+}
+
+// shutdown stops accepting requests, waits for in-flight requests and
+// asynchronous publishes, and then drains the producer and flushes the spans. A single deadline of
+// ShutdownTimeout covers the whole sequence.
+func shutdown(registry *registries.ServerAppRegistry, server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), registry.ShutdownTimeout)
+	defer cancel()
+	logger := registry.Logger
+
+	if err := server.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Warn("Error shutting down HTTP server", "error", err)
+	}
+	if err := registry.Publishes.Wait(ctx); err != nil {
+		logger.Warn("Gave up waiting for publishes", "pending", registry.Publishes.Len(), "error", err)
+	}
+	if err := registry.Producer.Close(); err != nil {
+		logger.Warn("Error closing producer", "error", err)
+	}
+	if registry.EmbeddedNATS != nil {
+		registry.EmbeddedNATS.Shutdown()
+	}
+	if registry.TracerProvider != nil {
+		if err := registry.TracerProvider.Shutdown(ctx); err != nil {
+			logger.Warn("Error flushing spans", "error", err)
+		}
+	}
+}
+
+// reloadConfigOnSIGHUP reloads the config, and the JSON schemas with it, every
+// time the process receives SIGHUP.
+func reloadConfigOnSIGHUP(registry *registries.ServerAppRegistry) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for range hup {
+		// ReloadConfig logs the outcome itself.
+		_ = registry.ReloadConfig()
+	}
 }
@@ -0,0 +1,234 @@
+package initializers
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"play.ground/generic-data-collector/internal/services"
+)
+
+const (
+	BrokerNATS      = "nats"
+	BrokerJetStream = "jetstream"
+	BrokerMemory    = "memory"
+	BrokerRedis     = "redis"
+
+	DefaultNATSURL           = "nats://localhost:4222"
+	DefaultJetStreamStream   = "INGEST"
+	DefaultJetStreamDurable  = "workers"
+	DefaultRedisURL          = "redis://localhost:6379/0"
+	DefaultRedisClaimMinIdle = 30 * time.Second
+	DefaultEmbeddedNATSHost  = "127.0.0.1"
+	DefaultEmbeddedNATSPort  = 4222
+	// DefaultTracingSampleRatio records every new trace.
+	DefaultTracingSampleRatio = 1.0
+)
+
+// LogConfig holds the LOG_* settings.
+type LogConfig struct {
+	// Level is the minimum level of every component.
+	Level string `mapstructure:"LOG_LEVEL"`
+	// Format is "json" or "text".
+	Format string `mapstructure:"LOG_FORMAT"`
+	// Levels maps component names to their own minimum level.
+	Levels map[string]string `mapstructure:"LOG_LEVELS"`
+}
+
+// BrokerConfig holds the settings of the message broker.
+type BrokerConfig struct {
+	// Type is "nats", "jetstream", "memory" or "redis".
+	Type string `mapstructure:"BROKER"`
+	// NATSURL is the URL, or comma-separated URLs, of the NATS servers.
+	NATSURL   string          `mapstructure:"NATS_URL" redact:"url"`
+	JetStream JetStreamConfig `mapstructure:",squash"`
+	Redis     RedisConfig     `mapstructure:",squash"`
+	Memory    MemoryConfig    `mapstructure:",squash"`
+	Embedded  EmbeddedConfig  `mapstructure:",squash"`
+}
+
+// JetStreamConfig holds the JETSTREAM_* settings, used with the jetstream broker.
+type JetStreamConfig struct {
+	Stream        string        `mapstructure:"JETSTREAM_STREAM"`
+	Subjects      []string      `mapstructure:"JETSTREAM_SUBJECTS"`
+	Retention     string        `mapstructure:"JETSTREAM_RETENTION"`
+	MaxAge        time.Duration `mapstructure:"JETSTREAM_MAX_AGE"`
+	MaxBytes      int64         `mapstructure:"JETSTREAM_MAX_BYTES"`
+	Replicas      int           `mapstructure:"JETSTREAM_REPLICAS"`
+	AckTimeout    time.Duration `mapstructure:"JETSTREAM_ACK_TIMEOUT"`
+	Durable       string        `mapstructure:"JETSTREAM_DURABLE"`
+	AckWait       time.Duration `mapstructure:"JETSTREAM_ACK_WAIT"`
+	MaxDeliver    int           `mapstructure:"JETSTREAM_MAX_DELIVER"`
+	MaxAckPending int           `mapstructure:"JETSTREAM_MAX_ACK_PENDING"`
+}
+
+// RedisConfig holds the REDIS_* settings, used with the redis broker.
+type RedisConfig struct {
+	URL    string `mapstructure:"REDIS_URL" redact:"url"`
+	MaxLen int64  `mapstructure:"REDIS_STREAM_MAX_LEN"`
+	// ClaimMinIdle is how long an entry of a consumer group stays unacknowledged
+	// before it is redelivered. It is also the redelivery delay of failed
+	// batches, since Redis cannot delay a redelivery.
+	ClaimMinIdle time.Duration `mapstructure:"REDIS_CLAIM_MIN_IDLE"`
+}
+
+// MemoryConfig holds the MEMORY_* settings, used with the memory broker.
+type MemoryConfig struct {
+	// BufferSize is the number of messages buffered by each subscription.
+	BufferSize int `mapstructure:"MEMORY_BUFFER_SIZE"`
+	// Overflow is "block", "drop_oldest" or "drop_newest".
+	Overflow string `mapstructure:"MEMORY_OVERFLOW"`
+}
+
+// EmbeddedConfig holds the NATS_EMBEDDED_* settings, which run a NATS server
+// inside the process for the nats and jetstream brokers.
+type EmbeddedConfig struct {
+	// Enabled starts the embedded server, which replaces NATS_URL.
+	Enabled bool   `mapstructure:"NATS_EMBEDDED"`
+	Host    string `mapstructure:"NATS_EMBEDDED_HOST"`
+	// Port is the client port; -1 picks a random free port.
+	Port int `mapstructure:"NATS_EMBEDDED_PORT"`
+	// StoreDir is where JetStream stores its streams; empty means a new
+	// temporary directory, removed on shutdown.
+	StoreDir string `mapstructure:"NATS_EMBEDDED_STORE_DIR"`
+}
+
+// TracingConfig holds the TRACING_* settings.
+type TracingConfig struct {
+	// Exporter is "none", "otlp", "stdout" or "file".
+	Exporter string `mapstructure:"TRACING_EXPORTER"`
+	Endpoint string `mapstructure:"TRACING_ENDPOINT"`
+	Insecure bool   `mapstructure:"TRACING_INSECURE"`
+	FilePath string `mapstructure:"TRACING_FILE_PATH"`
+	// SampleRatio is nil when not configured, since 0 records no new traces.
+	SampleRatio *float64 `mapstructure:"TRACING_SAMPLE_RATIO"`
+}
+
+func (c *BrokerConfig) setDefaults() {
+	if c.Type == "" {
+		c.Type = BrokerNATS
+	}
+	if c.NATSURL == "" {
+		c.NATSURL = DefaultNATSURL
+	}
+	if c.JetStream.Stream == "" {
+		c.JetStream.Stream = DefaultJetStreamStream
+	}
+	if c.JetStream.Durable == "" {
+		c.JetStream.Durable = DefaultJetStreamDurable
+	}
+	if len(c.JetStream.Subjects) == 0 {
+		c.JetStream.Subjects = []string{"metrics"}
+	}
+	if c.Redis.URL == "" {
+		c.Redis.URL = DefaultRedisURL
+	}
+	if c.Redis.ClaimMinIdle <= 0 {
+		c.Redis.ClaimMinIdle = DefaultRedisClaimMinIdle
+	}
+	if c.Memory.BufferSize == 0 {
+		c.Memory.BufferSize = services.DefaultMemoryBufferSize
+	}
+	if c.Memory.Overflow == "" {
+		c.Memory.Overflow = services.OverflowBlock
+	}
+	if c.Embedded.Host == "" {
+		c.Embedded.Host = DefaultEmbeddedNATSHost
+	}
+	if c.Embedded.Port == 0 {
+		c.Embedded.Port = DefaultEmbeddedNATSPort
+	}
+}
+
+func (c *TracingConfig) setDefaults() {
+	if c.Exporter == "" {
+		c.Exporter = services.TracingExporterNone
+	}
+	if c.SampleRatio == nil {
+		ratio := DefaultTracingSampleRatio
+		c.SampleRatio = &ratio
+	}
+}
+
+func (c LogConfig) validate() []error {
+	var errs []error
+	if err := (services.LogConfig{Level: c.Level}).Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL: %w", err))
+	}
+	if err := (services.LogConfig{Format: c.Format}).Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT: %w", err))
+	}
+	if err := (services.LogConfig{Components: c.Levels}).Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVELS: %w", err))
+	}
+	return errs
+}
+
+func (c BrokerConfig) validate() []error {
+	var errs []error
+	switch c.Type {
+	case BrokerNATS:
+	case BrokerMemory:
+		errs = checkNonNegative(errs, "MEMORY_BUFFER_SIZE", c.Memory.BufferSize)
+		if err := (services.MemoryBrokerConfig{Overflow: c.Memory.Overflow}).Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("MEMORY_OVERFLOW: %w", err))
+		}
+	case BrokerJetStream:
+		for _, subject := range c.JetStream.Subjects {
+			if err := services.ValidateSubject(subject, true); err != nil {
+				errs = append(errs, fmt.Errorf("JETSTREAM_SUBJECTS: %w", err))
+			}
+		}
+	case BrokerRedis:
+		if err := services.ValidateRedisURL(c.Redis.URL); err != nil {
+			errs = append(errs, fmt.Errorf("REDIS_URL: %w", err))
+		}
+		errs = checkNonNegative(errs, "REDIS_STREAM_MAX_LEN", c.Redis.MaxLen)
+	default:
+		errs = append(errs, fmt.Errorf("BROKER: invalid value %q: expected %q, %q, %q or %q", c.Type, BrokerNATS, BrokerJetStream, BrokerMemory, BrokerRedis))
+	}
+	if c.Embedded.Enabled {
+		if c.Type != BrokerNATS && c.Type != BrokerJetStream {
+			errs = append(errs, fmt.Errorf("NATS_EMBEDDED: requires the %q or %q broker", BrokerNATS, BrokerJetStream))
+		}
+		if c.Embedded.Port < -1 || c.Embedded.Port > 65535 {
+			errs = append(errs, fmt.Errorf("NATS_EMBEDDED_PORT: %d is not a port number", c.Embedded.Port))
+		}
+	}
+	return errs
+}
+
+func (c TracingConfig) validate() []error {
+	var errs []error
+	switch c.Exporter {
+	case services.TracingExporterNone, services.TracingExporterOTLP, services.TracingExporterStdout:
+	case services.TracingExporterFile:
+		if c.FilePath == "" {
+			errs = append(errs, fmt.Errorf("TRACING_FILE_PATH: required by the %q exporter", c.Exporter))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("TRACING_EXPORTER: invalid value %q: expected %q, %q, %q or %q", c.Exporter,
+			services.TracingExporterNone, services.TracingExporterOTLP, services.TracingExporterStdout, services.TracingExporterFile))
+	}
+	if c.SampleRatio != nil && (*c.SampleRatio < 0 || *c.SampleRatio > 1) {
+		errs = append(errs, fmt.Errorf("TRACING_SAMPLE_RATIO: %v is not between 0 and 1", *c.SampleRatio))
+	}
+	return errs
+}
+
+// checkNonNegative appends an error to errs if the setting is negative.
+func checkNonNegative[T int | int64 | float64 | time.Duration](errs []error, key string, value T) []error {
+	if value < 0 {
+		return append(errs, fmt.Errorf("%s: must not be negative, got %v", key, value))
+	}
+	return errs
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,15 +1,116 @@
 package initializers
 
-import "github.com/spf13/viper"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 
-func NewConfig(env string) *viper.Viper {
+	"github.com/spf13/viper"
+)
+
+const (
+	// ConfigFileEnv names the environment variable that selects the config
+	// file when the --config flag is not given.
+	ConfigFileEnv = "CONFIG_FILE"
+	// DefaultConfigDir holds the config file of each environment.
+	DefaultConfigDir = "./config"
+)
+
+// Env returns the environment named by GO_ENV, defaulting to "development".
+func Env() string {
+	if env := os.Getenv("GO_ENV"); env != "" {
+		return env
+	}
+	return "development"
+}
+
+// ConfigPath returns the config file to read: the given --config flag value,
+// then the CONFIG_FILE environment variable, then the file of the current
+// environment in DefaultConfigDir.
+func ConfigPath(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if path := os.Getenv(ConfigFileEnv); path != "" {
+		return path
+	}
+	return filepath.Join(DefaultConfigDir, Env()+".yml")
+}
+
+// NewConfig reads the config file at path. Environment variables named after
+// a setting override the value in the file.
+func NewConfig(path string) (*viper.Viper, error) {
 	config := viper.New()
-	config.AddConfigPath("./config")
-	config.SetConfigName(env)
-	err := config.ReadInConfig()
-	if err != nil {
-		panic(err)
+	config.SetConfigFile(path)
+	if err := config.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 	config.AutomaticEnv()
-	return config
+	return config, nil
+}
+
+// decode unmarshals the settings over target, which holds the defaults.
+// Settings missing from the file are only looked up in the environment if
+// they are bound, so every setting of target is bound first.
+func decode(config *viper.Viper, target any) error {
+	for _, key := range settingKeys(reflect.TypeOf(target).Elem()) {
+		if err := config.BindEnv(key); err != nil {
+			return err
+		}
+	}
+	if err := config.Unmarshal(target); err != nil {
+		return fmt.Errorf("failed to decode config: %w", err)
+	}
+	return nil
+}
+
+// settingKeys returns the setting names of a config struct, following squashed
+// groups of settings.
+func settingKeys(t reflect.Type) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		switch {
+		case opts == "squash":
+			keys = append(keys, settingKeys(field.Type)...)
+		case name != "":
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// ChangedSettings returns the sorted names of the settings that differ between
+// two ServerConfigs or two WorkerConfigs.
+func ChangedSettings(old, new any) []string {
+	oldValues, newValues := make(map[string]any), make(map[string]any)
+	addValues(oldValues, reflect.ValueOf(old))
+	addValues(newValues, reflect.ValueOf(new))
+
+	var changed []string
+	for _, key := range sortedKeys(newValues) {
+		if !reflect.DeepEqual(oldValues[key], newValues[key]) {
+			changed = append(changed, key)
+		}
+	}
+	return changed
+}
+
+// addValues adds the value of every setting of a config struct to values,
+// following squashed groups of settings.
+func addValues(values map[string]any, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		switch {
+		case opts == "squash":
+			addValues(values, v.Field(i))
+		case name != "":
+			values[name] = v.Field(i).Interface()
+		}
+	}
 }
@@ -0,0 +1,115 @@
+package initializers
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redactedValue replaces secret settings when the config is printed.
+const redactedValue = "REDACTED"
+
+// WriteRedacted writes the settings of a ServerConfig or WorkerConfig as YAML,
+// in the format of the config files. Secrets are redacted: fields tagged
+// `redact:"true"` are replaced entirely, and the passwords of URLs in fields
+// tagged `redact:"url"` are masked.
+func WriteRedacted(w io.Writer, cfg any) error {
+	settings := make(map[string]any)
+	addSettings(settings, reflect.ValueOf(cfg))
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(settings); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func addSettings(settings map[string]any, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if opts == "squash" {
+			addSettings(settings, v.Field(i))
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		settings[name] = redact(v.Field(i), field.Tag.Get("redact"))
+	}
+}
+
+func redact(v reflect.Value, mode string) any {
+	switch {
+	case mode == "true":
+		if v.IsZero() {
+			return v.Interface()
+		}
+		return redactedValue
+	case mode == "url":
+		return redactURLs(v.String())
+	}
+
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return redact(v.Elem(), "")
+	case reflect.Struct:
+		settings := make(map[string]any)
+		addSettings(settings, v)
+		return settings
+	case reflect.Slice:
+		values := make([]any, v.Len())
+		for i := range values {
+			values[i] = redact(v.Index(i), "")
+		}
+		return values
+	case reflect.Map:
+		values := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			values[fmt.Sprint(iter.Key().Interface())] = redact(iter.Value(), "")
+		}
+		return values
+	}
+	return v.Interface()
+}
+
+// redactURLs masks the credentials of a comma-separated list of URLs: the
+// password, or the user name when it is used alone as a token. A list that
+// does not parse may hold credentials where they cannot be told apart, so it
+// is replaced entirely.
+func redactURLs(urls string) string {
+	parts := strings.Split(urls, ",")
+	for i, part := range parts {
+		u, err := url.Parse(strings.TrimSpace(part))
+		if err != nil {
+			return redactedValue
+		}
+		if u.User == nil {
+			continue
+		}
+		if _, ok := u.User.Password(); ok {
+			parts[i] = u.Redacted()
+		} else {
+			u.User = url.User(redactedValue)
+			parts[i] = u.String()
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,163 @@
+package initializers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/spf13/viper"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+const (
+	PublishModeAsync = "async"
+	PublishModeSync  = "sync"
+
+	DefaultHTTPAddr        = ":8080"
+	DefaultShutdownTimeout = 15 * time.Second
+	DefaultMaxBodyBytes    = 10 << 20
+	DefaultPublishTimeout  = 2 * time.Second
+)
+
+// ServerConfig holds the settings of the API server.
+type ServerConfig struct {
+	Log     LogConfig     `mapstructure:",squash"`
+	Broker  BrokerConfig  `mapstructure:",squash"`
+	Tracing TracingConfig `mapstructure:",squash"`
+
+	// HTTPAddr is the address the HTTP server listens on.
+	HTTPAddr string `mapstructure:"HTTP_ADDR"`
+	// ShutdownTimeout bounds how long shutdown waits for in-flight requests
+	// and publishes.
+	ShutdownTimeout time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	// TrustedProxies are the IPs and CIDRs of the proxies whose
+	// X-Forwarded-For headers are trusted.
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES"`
+	// MaxBodyBytes caps the size of request bodies after decompression.
+	MaxBodyBytes int64 `mapstructure:"MAX_BODY_BYTES"`
+
+	// PublishMode is "async" or "sync".
+	PublishMode string `mapstructure:"PUBLISH_MODE"`
+	// PublishTimeout bounds how long a synchronous publish may wait for confirmation.
+	PublishTimeout time.Duration `mapstructure:"PUBLISH_TIMEOUT"`
+
+	// Topics maps the topics accepted by the API to broker subjects.
+	Topics map[string]string `mapstructure:"TOPICS"`
+	// SchemaDir holds the JSON Schemas of the topics; empty disables validation.
+	SchemaDir string `mapstructure:"SCHEMA_DIR"`
+
+	// APIKeys are the keys accepted in addition to those of APIKeysFile.
+	APIKeys     []services.APIKey `mapstructure:"API_KEYS"`
+	APIKeysFile string            `mapstructure:"API_KEYS_FILE"`
+
+	// RateLimit holds the default limits of every client; nil disables rate
+	// limiting unless RateLimitClients is set.
+	RateLimit *services.RateLimit `mapstructure:"RATE_LIMIT"`
+	// RateLimitClients maps client IDs to their own limits.
+	RateLimitClients map[string]services.RateLimit `mapstructure:"RATE_LIMIT_CLIENTS"`
+	// RateLimitIP limits the requests of every IP address before they are
+	// authenticated, so that requests with unknown keys are limited too; nil
+	// disables it. Only the request budgets apply.
+	RateLimitIP *services.RateLimit `mapstructure:"RATE_LIMIT_IP"`
+}
+
+// DefaultServerConfig returns the settings used when nothing is configured.
+func DefaultServerConfig() ServerConfig {
+	var cfg ServerConfig
+	cfg.setDefaults()
+	return cfg
+}
+
+// LoadServerConfig decodes the server settings, applies the defaults and
+// validates the result. The error lists every invalid setting.
+func LoadServerConfig(config *viper.Viper) (ServerConfig, error) {
+	var cfg ServerConfig
+	if err := decode(config, &cfg); err != nil {
+		return cfg, err
+	}
+	cfg.setDefaults()
+	return cfg, cfg.Validate()
+}
+
+// setDefaults fills the settings that are not configured. Zero values are
+// treated as not configured.
+func (c *ServerConfig) setDefaults() {
+	c.Broker.setDefaults()
+	c.Tracing.setDefaults()
+	if c.HTTPAddr == "" {
+		c.HTTPAddr = DefaultHTTPAddr
+	}
+	if c.ShutdownTimeout <= 0 {
+		c.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if c.PublishMode == "" {
+		c.PublishMode = PublishModeAsync
+	}
+	if c.PublishTimeout <= 0 {
+		c.PublishTimeout = DefaultPublishTimeout
+	}
+	if len(c.Topics) == 0 {
+		c.Topics = DefaultTopics()
+	}
+}
+
+// Validate reports every invalid setting.
+func (c ServerConfig) Validate() error {
+	var errs []error
+	errs = append(errs, c.Log.validate()...)
+	errs = append(errs, c.Broker.validate()...)
+	errs = append(errs, c.Tracing.validate()...)
+
+	if c.PublishMode != PublishModeAsync && c.PublishMode != PublishModeSync {
+		errs = append(errs, fmt.Errorf("PUBLISH_MODE: invalid value %q: expected %q or %q", c.PublishMode, PublishModeAsync, PublishModeSync))
+	}
+	if _, err := services.NewTopicRouter(c.Topics); err != nil {
+		errs = append(errs, fmt.Errorf("TOPICS: %w", err))
+	}
+	for _, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("TRUSTED_PROXIES: %q is neither an IP nor a CIDR", proxy))
+			}
+		}
+	}
+	for i, key := range c.APIKeys {
+		if key.Client == "" || key.SHA256 == "" {
+			errs = append(errs, fmt.Errorf("API_KEYS: key %d needs a client and a sha256", i))
+		}
+	}
+	if c.RateLimit != nil {
+		errs = append(errs, validateRateLimit("RATE_LIMIT", *c.RateLimit)...)
+	}
+	for _, client := range sortedKeys(c.RateLimitClients) {
+		errs = append(errs, validateRateLimit("RATE_LIMIT_CLIENTS."+client, c.RateLimitClients[client])...)
+	}
+	if c.RateLimitIP != nil {
+		errs = append(errs, validateRateLimit("RATE_LIMIT_IP", *c.RateLimitIP)...)
+		// Bodies are only read once authenticated, and charged to the client.
+		if c.RateLimitIP.BytesPerSecond != 0 || c.RateLimitIP.ByteBurst != 0 || c.RateLimitIP.DailyBytes != 0 {
+			errs = append(errs, errors.New("RATE_LIMIT_IP: byte limits do not apply before authentication"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateRateLimit(key string, limit services.RateLimit) []error {
+	var errs []error
+	errs = checkNonNegative(errs, key+".requests_per_second", limit.RequestsPerSecond)
+	errs = checkNonNegative(errs, key+".request_burst", limit.RequestBurst)
+	errs = checkNonNegative(errs, key+".bytes_per_second", limit.BytesPerSecond)
+	errs = checkNonNegative(errs, key+".byte_burst", limit.ByteBurst)
+	errs = checkNonNegative(errs, key+".daily_requests", limit.DailyRequests)
+	errs = checkNonNegative(errs, key+".daily_bytes", limit.DailyBytes)
+	return errs
+}
+
+// DefaultTopics returns the topic routes used when none are configured.
+func DefaultTopics() map[string]string {
+	return map[string]string{"metrics": "metrics"}
+}
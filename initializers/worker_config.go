@@ -0,0 +1,208 @@
+package initializers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+const (
+	SinkLog  = "log"
+	SinkFile = "file"
+	SinkHTTP = "http"
+
+	DefaultAdminAddr        = ":8081"
+	DefaultDeadLetterStream = "DEAD_LETTERS"
+)
+
+// WorkerConfig holds the settings of the consumer worker.
+type WorkerConfig struct {
+	Log     LogConfig     `mapstructure:",squash"`
+	Broker  BrokerConfig  `mapstructure:",squash"`
+	Tracing TracingConfig `mapstructure:",squash"`
+
+	// AdminAddr is the address of the admin HTTP listener serving the health
+	// and metrics endpoints.
+	AdminAddr string `mapstructure:"ADMIN_ADDR"`
+	// RunWithBatches writes records to the sink in batches; otherwise the
+	// worker only acknowledges them.
+	RunWithBatches bool `mapstructure:"RUN_WITH_BATCHES"`
+	// Topics are the subjects the worker subscribes to; they may contain wildcards.
+	Topics []string `mapstructure:"CONSUMER_TOPICS"`
+	// QueueGroup is the queue group shared by all workers; empty means every
+	// worker receives every message.
+	QueueGroup string `mapstructure:"QUEUE_GROUP"`
+
+	Batch      BatchConfig      `mapstructure:",squash"`
+	Retry      RetryConfig      `mapstructure:",squash"`
+	Sink       SinkConfig       `mapstructure:",squash"`
+	DeadLetter DeadLetterConfig `mapstructure:",squash"`
+}
+
+// BatchConfig holds the thresholds that trigger a flush and the number of
+// batches written in parallel. A zero threshold is disabled.
+type BatchConfig struct {
+	MaxRecords       int           `mapstructure:"BATCH_MAX_RECORDS"`
+	MaxBytes         int           `mapstructure:"BATCH_MAX_BYTES"`
+	MaxAge           time.Duration `mapstructure:"BATCH_MAX_AGE"`
+	FlushConcurrency int           `mapstructure:"FLUSH_CONCURRENCY"`
+	FlushMaxInFlight int           `mapstructure:"FLUSH_MAX_IN_FLIGHT"`
+}
+
+// RetryConfig holds the RETRY_* settings of sink writes.
+type RetryConfig struct {
+	MaxAttempts     int           `mapstructure:"RETRY_MAX_ATTEMPTS"`
+	InitialInterval time.Duration `mapstructure:"RETRY_INITIAL_INTERVAL"`
+	MaxInterval     time.Duration `mapstructure:"RETRY_MAX_INTERVAL"`
+	Multiplier      float64       `mapstructure:"RETRY_MULTIPLIER"`
+	MaxElapsedTime  time.Duration `mapstructure:"RETRY_MAX_ELAPSED_TIME"`
+}
+
+// SinkConfig holds the SINK_* settings.
+type SinkConfig struct {
+	// Type is "log", "file" or "http".
+	Type          string        `mapstructure:"SINK"`
+	FilePath      string        `mapstructure:"SINK_FILE_PATH"`
+	HTTPURL       string        `mapstructure:"SINK_HTTP_URL" redact:"url"`
+	HTTPHealthURL string        `mapstructure:"SINK_HTTP_HEALTH_URL" redact:"url"`
+	HTTPTimeout   time.Duration `mapstructure:"SINK_HTTP_TIMEOUT"`
+}
+
+// DeadLetterConfig holds the DLQ_* settings.
+type DeadLetterConfig struct {
+	// Subject receives the records that exhausted their retries; empty
+	// disables dead-lettering.
+	Subject string `mapstructure:"DLQ_SUBJECT"`
+	// Stream and MaxAge describe the JetStream stream keeping the dead letters.
+	Stream string        `mapstructure:"DLQ_STREAM"`
+	MaxAge time.Duration `mapstructure:"DLQ_MAX_AGE"`
+}
+
+// DefaultWorkerConfig returns the settings used when nothing is configured.
+func DefaultWorkerConfig() WorkerConfig {
+	cfg := newWorkerConfig()
+	cfg.setDefaults()
+	return cfg
+}
+
+// LoadWorkerConfig decodes the worker settings, applies the defaults and
+// validates the result. The error lists every invalid setting.
+func LoadWorkerConfig(config *viper.Viper) (WorkerConfig, error) {
+	cfg := newWorkerConfig()
+	if err := decode(config, &cfg); err != nil {
+		return cfg, err
+	}
+	cfg.setDefaults()
+	return cfg, cfg.Validate()
+}
+
+// newWorkerConfig returns the defaults of the settings whose zero value is
+// meaningful, which therefore cannot be filled in after decoding.
+func newWorkerConfig() WorkerConfig {
+	limits := services.DefaultBatchLimits()
+	policy := services.DefaultRetryPolicy()
+	return WorkerConfig{
+		Batch: BatchConfig{
+			MaxRecords: limits.MaxRecords,
+			MaxBytes:   limits.MaxBytes,
+			MaxAge:     limits.MaxAge,
+		},
+		Retry: RetryConfig{
+			MaxAttempts:     policy.MaxAttempts,
+			InitialInterval: policy.InitialInterval,
+			MaxInterval:     policy.MaxInterval,
+			Multiplier:      policy.Multiplier,
+			MaxElapsedTime:  policy.MaxElapsedTime,
+		},
+	}
+}
+
+// setDefaults fills the settings that are not configured.
+func (c *WorkerConfig) setDefaults() {
+	c.Broker.setDefaults()
+	c.Tracing.setDefaults()
+	if c.AdminAddr == "" {
+		c.AdminAddr = DefaultAdminAddr
+	}
+	if len(c.Topics) == 0 {
+		c.Topics = []string{"metrics"}
+	}
+	if c.Sink.Type == "" {
+		c.Sink.Type = SinkLog
+	}
+	if c.DeadLetter.Stream == "" {
+		c.DeadLetter.Stream = DefaultDeadLetterStream
+	}
+}
+
+// Validate reports every invalid setting.
+func (c WorkerConfig) Validate() error {
+	var errs []error
+	errs = append(errs, c.Log.validate()...)
+	errs = append(errs, c.Broker.validate()...)
+	errs = append(errs, c.Tracing.validate()...)
+
+	// Redis streams are looked up by name, so they cannot be matched by wildcards.
+	allowWildcards := c.Broker.Type != BrokerRedis
+	for _, topic := range c.Topics {
+		if err := services.ValidateSubject(topic, allowWildcards); err != nil {
+			errs = append(errs, fmt.Errorf("CONSUMER_TOPICS: %w", err))
+		}
+	}
+
+	errs = checkNonNegative(errs, "BATCH_MAX_RECORDS", c.Batch.MaxRecords)
+	errs = checkNonNegative(errs, "BATCH_MAX_BYTES", c.Batch.MaxBytes)
+	errs = checkNonNegative(errs, "BATCH_MAX_AGE", c.Batch.MaxAge)
+	limits := services.BatchLimits{MaxRecords: c.Batch.MaxRecords, MaxBytes: c.Batch.MaxBytes, MaxAge: c.Batch.MaxAge}
+	if err := limits.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("BATCH_MAX_RECORDS, BATCH_MAX_BYTES, BATCH_MAX_AGE: %w", err))
+	}
+	errs = checkNonNegative(errs, "FLUSH_CONCURRENCY", c.Batch.FlushConcurrency)
+	errs = checkNonNegative(errs, "FLUSH_MAX_IN_FLIGHT", c.Batch.FlushMaxInFlight)
+
+	errs = checkNonNegative(errs, "RETRY_MAX_ATTEMPTS", c.Retry.MaxAttempts)
+	errs = checkNonNegative(errs, "RETRY_INITIAL_INTERVAL", c.Retry.InitialInterval)
+	errs = checkNonNegative(errs, "RETRY_MAX_INTERVAL", c.Retry.MaxInterval)
+	errs = checkNonNegative(errs, "RETRY_MULTIPLIER", c.Retry.Multiplier)
+	errs = checkNonNegative(errs, "RETRY_MAX_ELAPSED_TIME", c.Retry.MaxElapsedTime)
+
+	switch c.Sink.Type {
+	case SinkLog:
+	case SinkFile:
+		if c.Sink.FilePath == "" {
+			errs = append(errs, fmt.Errorf("SINK_FILE_PATH: required by the %q sink", c.Sink.Type))
+		}
+	case SinkHTTP:
+		if c.Sink.HTTPURL == "" {
+			errs = append(errs, fmt.Errorf("SINK_HTTP_URL: required by the %q sink", c.Sink.Type))
+		}
+		errs = checkNonNegative(errs, "SINK_HTTP_TIMEOUT", c.Sink.HTTPTimeout)
+	default:
+		errs = append(errs, fmt.Errorf("SINK: invalid value %q: expected %q, %q or %q", c.Sink.Type, SinkLog, SinkFile, SinkHTTP))
+	}
+
+	if c.DeadLetter.Subject != "" {
+		if err := services.ValidateSubject(c.DeadLetter.Subject, false); err != nil {
+			errs = append(errs, fmt.Errorf("DLQ_SUBJECT: %w", err))
+		}
+		// The worker would consume its own dead letters again and again.
+		for _, topic := range c.Topics {
+			if services.MatchSubject(topic, c.DeadLetter.Subject) {
+				errs = append(errs, fmt.Errorf("DLQ_SUBJECT: %q is consumed by CONSUMER_TOPICS %q", c.DeadLetter.Subject, topic))
+			}
+		}
+		// Dead letters have a stream of their own, and JetStream rejects
+		// streams with overlapping subjects.
+		if c.Broker.Type == BrokerJetStream {
+			for _, subject := range c.Broker.JetStream.Subjects {
+				if services.MatchSubject(subject, c.DeadLetter.Subject) {
+					errs = append(errs, fmt.Errorf("DLQ_SUBJECT: %q overlaps JETSTREAM_SUBJECTS %q", c.DeadLetter.Subject, subject))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"play.ground/generic-data-collector/internal/interfaces"
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// Authenticate is a middleware that resolves the API key of the request, sent
+// either as a bearer token or in the X-API-Key header, and stores the client in
+// the request context. Requests without a valid key are rejected with 401.
+// Authentication is disabled when the registry has no API keys.
+func Authenticate(c *gin.Context, registry *registries.ServerAppRegistry) {
+	if registry.APIKeys == nil {
+		return
+	}
+
+	key := apiKeyFromRequest(c.Request)
+	if key == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="api"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing API key"})
+		return
+	}
+
+	client, ok := registry.APIKeys.Authenticate(key)
+	if !ok {
+		c.Header("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+		return
+	}
+
+	c.Request = c.Request.WithContext(services.ContextWithClient(c.Request.Context(), client))
+}
+
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return key
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// authorizeTopic responds with 403 if the authenticated client may not write
+// to the topic. Unauthenticated requests are only possible with authentication
+// disabled, so they are allowed.
+func authorizeTopic(c *gin.Context, topic string) bool {
+	client, ok := services.ClientFromContext(c.Request.Context())
+	if !ok || client.CanWrite(topic) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "API key may not write to this topic", "topic": topic})
+	return false
+}
+
+// messageHeader returns the header identifying the client that sent the request
+// and carrying the trace context of ctx.
+func messageHeader(ctx context.Context) interfaces.Header {
+	var header interfaces.Header
+	if client, ok := services.ClientFromContext(ctx); ok {
+		header = interfaces.Header{interfaces.HeaderClientID: client.ID}
+	}
+	return services.InjectTraceContext(ctx, header)
+}
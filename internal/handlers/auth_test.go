@@ -0,0 +1,96 @@
+package handlers_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"play.ground/generic-data-collector/internal/handlers"
+	"play.ground/generic-data-collector/internal/interfaces"
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAuthRouter(t *testing.T) (*gin.Engine, *services.MockProducer) {
+	gin.SetMode(gin.TestMode)
+
+	registry := registries.NewMockServerAppRegistry()
+	registry.SyncPublish = true
+	topics, err := services.NewTopicRouter(map[string]string{
+		"metrics": "metrics",
+		"events":  "ingest.events",
+	})
+	require.NoError(t, err)
+	registry.Topics = topics
+	registry.APIKeys, err = services.NewAPIKeyStore([]services.APIKey{
+		{Client: "dashboard", SHA256: services.HashAPIKey("dashboard-key"), Topics: []string{"metrics"}},
+	})
+	require.NoError(t, err)
+
+	withRegistry := func(handler func(*gin.Context, *registries.ServerAppRegistry)) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			handler(c, registry)
+		}
+	}
+	router := gin.New()
+	v1 := router.Group("/api/v1")
+	v1.Use(withRegistry(handlers.Authenticate))
+	v1.POST("/metrics", withRegistry(handlers.PostMetric))
+	v1.POST("/ingest/:topic/batch", withRegistry(handlers.PostIngestBatch))
+	return router, registry.Producer.(*services.MockProducer)
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		header http.Header
+		status int
+	}{
+		{"missing key", "/api/v1/metrics", http.Header{}, http.StatusUnauthorized},
+		{"invalid key", "/api/v1/metrics", http.Header{"X-Api-Key": {"wrong"}}, http.StatusUnauthorized},
+		{"api key header", "/api/v1/metrics", http.Header{"X-Api-Key": {"dashboard-key"}}, http.StatusAccepted},
+		{"bearer token", "/api/v1/metrics", http.Header{"Authorization": {"Bearer dashboard-key"}}, http.StatusAccepted},
+		{"forbidden topic", "/api/v1/ingest/events/batch", http.Header{"X-Api-Key": {"dashboard-key"}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockProducer := newAuthRouter(t)
+
+			body := `{"value": 1}`
+			if tt.path != "/api/v1/metrics" {
+				body = `[{"value": 1}]`
+			}
+			req, _ := http.NewRequest(http.MethodPost, tt.path, bytes.NewBufferString(body))
+			req.Header = tt.header
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status != http.StatusAccepted {
+				assert.Empty(t, mockProducer.Published())
+			}
+		})
+	}
+}
+
+func TestAuthenticate_AttachesClientToMessages(t *testing.T) {
+	router, mockProducer := newAuthRouter(t)
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/metrics", bytes.NewBufferString(`{"value": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer dashboard-key")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusAccepted, w.Code)
+	published := mockProducer.Published()
+	require.Len(t, published, 1)
+	assert.Equal(t, interfaces.Header{interfaces.HeaderClientID: "dashboard"}, published[0].Header)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"play.ground/generic-data-collector/internal/registries"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Decompress is a middleware that decodes request bodies sent with a
+// Content-Encoding of gzip, deflate or zstd, and caps the size of every body,
+// after decompression, at the registry's MaxBodyBytes. Handlers reading past
+// the cap get an *http.MaxBytesError, reported to the client as 413.
+func Decompress(c *gin.Context, registry *registries.ServerAppRegistry) {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return
+	}
+	if registry.MaxBodyBytes > 0 && c.Request.ContentLength > registry.MaxBodyBytes {
+		abortTooLarge(c, registry.MaxBodyBytes)
+		return
+	}
+
+	body, err := decodeBody(c.Request.Body, c.Request.Header.Get("Content-Encoding"), registry.MaxBodyBytes)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errUnsupportedEncoding) {
+			status = http.StatusUnsupportedMediaType
+		}
+		c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if body != c.Request.Body {
+		// Decoders keep state of their own; release it along with the original body.
+		defer body.Close()
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.ContentLength = -1
+	}
+
+	if registry.MaxBodyBytes > 0 {
+		body = http.MaxBytesReader(c.Writer, body, registry.MaxBodyBytes)
+	}
+	c.Request.Body = body
+	c.Next()
+}
+
+var errUnsupportedEncoding = errors.New("unsupported Content-Encoding")
+
+// decodeBody wraps the body in a decoder for every coding listed in the
+// Content-Encoding header, in the reverse order of their application.
+func decodeBody(body io.ReadCloser, contentEncoding string, maxBytes int64) (io.ReadCloser, error) {
+	if contentEncoding == "" {
+		return body, nil
+	}
+
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		var err error
+		switch coding {
+		case "identity", "":
+			continue
+		case "gzip", "x-gzip":
+			body, err = gzip.NewReader(body)
+		case "deflate":
+			body, err = newDeflateReader(body)
+		case "zstd":
+			body, err = newZstdReader(body, maxBytes)
+		default:
+			return nil, fmt.Errorf("%w %q: expected gzip, deflate or zstd", errUnsupportedEncoding, coding)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s body: %w", coding, err)
+		}
+	}
+	return body, nil
+}
+
+// newDeflateReader decodes a deflate body. The deflate coding is defined as
+// zlib-wrapped data, but some clients send raw deflate, so both are accepted.
+func newDeflateReader(body io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(body)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	// A zlib header uses the deflate method and is a multiple of 31.
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// newZstdReader decodes a zstd body. The decoder's window is bounded by the body
+// cap, so a crafted frame cannot make it allocate more than the body may hold.
+func newZstdReader(body io.Reader, maxBytes int64) (io.ReadCloser, error) {
+	opts := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+	if maxBytes > 0 {
+		opts = append(opts, zstd.WithDecoderMaxMemory(uint64(maxBytes)))
+	}
+	dec, err := zstd.NewReader(body, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return dec.IOReadCloser(), nil
+}
+
+// isBodyTooLarge reports whether reading the request body failed because the
+// body, or a zstd frame within it, exceeds the size cap.
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr) ||
+		errors.Is(err, zstd.ErrDecoderSizeExceeded) ||
+		errors.Is(err, zstd.ErrWindowSizeExceeded)
+}
+
+// abortTooLarge responds with 413 for a body exceeding the size cap.
+func abortTooLarge(c *gin.Context, maxBytes int64) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error":     fmt.Sprintf("request body exceeds %d bytes", maxBytes),
+		"max_bytes": maxBytes,
+	})
+}
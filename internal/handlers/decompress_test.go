@@ -0,0 +1,134 @@
+package handlers_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"play.ground/generic-data-collector/internal/handlers"
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDecompressRouter(t *testing.T, maxBodyBytes int64) (*gin.Engine, *services.MockProducer) {
+	gin.SetMode(gin.TestMode)
+
+	registry := registries.NewMockServerAppRegistry()
+	registry.SyncPublish = true
+	registry.MaxBodyBytes = maxBodyBytes
+
+	withRegistry := func(handler func(*gin.Context, *registries.ServerAppRegistry)) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			handler(c, registry)
+		}
+	}
+	router := gin.New()
+	v1 := router.Group("/api/v1")
+	v1.Use(withRegistry(handlers.Decompress))
+	v1.POST("/metrics", withRegistry(handlers.PostMetric))
+	v1.POST("/metrics/batch", withRegistry(handlers.PostMetricsBatch))
+	return router, registry.Producer.(*services.MockProducer)
+}
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "zstd":
+		w, err = zstd.NewWriter(&buf)
+	}
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func postEncoded(router *gin.Engine, path, encoding string, body []byte) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestDecompress_Encodings(t *testing.T) {
+	record := []byte(`{"value": 42}`)
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+	}{
+		{"identity", "", record},
+		{"gzip", "gzip", compress(t, "gzip", record)},
+		{"deflate", "deflate", compress(t, "deflate", record)},
+		{"raw deflate", "deflate", compress(t, "raw-deflate", record)},
+		{"zstd", "zstd", compress(t, "zstd", record)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockProducer := newDecompressRouter(t, 1<<20)
+
+			w := postEncoded(router, "/api/v1/metrics", tt.encoding, tt.body)
+
+			assert.Equal(t, http.StatusAccepted, w.Code)
+			published := mockProducer.Published()
+			require.Len(t, published, 1)
+			assert.JSONEq(t, string(record), string(published[0].Data))
+		})
+	}
+}
+
+func TestDecompress_Errors(t *testing.T) {
+	// Highly compressible records that decompress far beyond the cap.
+	bomb := append([]byte(`{"value": "`), bytes.Repeat([]byte("0"), 1<<20)...)
+	bomb = append(bomb, `"}`...)
+	batchBomb := append(append([]byte("["), bomb...), ']')
+
+	tests := []struct {
+		name     string
+		path     string
+		encoding string
+		body     []byte
+		status   int
+	}{
+		{"unsupported encoding", "/api/v1/metrics", "br", []byte("{}"), http.StatusUnsupportedMediaType},
+		{"corrupt gzip", "/api/v1/metrics", "gzip", []byte("not gzip"), http.StatusBadRequest},
+		{"gzip bomb", "/api/v1/metrics", "gzip", compress(t, "gzip", bomb), http.StatusRequestEntityTooLarge},
+		{"zstd bomb", "/api/v1/metrics", "zstd", compress(t, "zstd", bomb), http.StatusRequestEntityTooLarge},
+		{"batch bomb", "/api/v1/metrics/batch", "gzip", compress(t, "gzip", batchBomb), http.StatusRequestEntityTooLarge},
+		{"declared length over cap", "/api/v1/metrics", "", bomb, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockProducer := newDecompressRouter(t, 64<<10)
+
+			w := postEncoded(router, tt.path, tt.encoding, tt.body)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Empty(t, mockProducer.Published())
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+
+	"play.ground/generic-data-collector/internal/interfaces"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Liveness is the handler for /healthz. It only tells that the process is able
+// to serve requests and does not check dependencies, so that an orchestrator
+// does not restart the process while the broker is unreachable.
+func Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": services.HealthStatusOK})
+}
+
+// Readiness returns the handler for /readyz, which checks every dependency and
+// responds with 503 unless all of them are healthy. The body breaks the result
+// down per dependency.
+func Readiness(checks map[string]interfaces.HealthChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := services.CheckHealth(c.Request.Context(), checks)
+		status := http.StatusOK
+		if !report.Healthy() {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"play.ground/generic-data-collector/internal/handlers"
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	registry := registries.NewMockServerAppRegistry()
+	mockProducer := registry.Producer.(*services.MockProducer)
+
+	router := gin.New()
+	router.GET("/healthz", handlers.Liveness)
+	router.GET("/readyz", handlers.Readiness(registry.HealthChecks()))
+
+	get := func(path string) (int, services.HealthReport) {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var report services.HealthReport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		return w.Code, report
+	}
+
+	code, report := get("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, services.HealthStatusOK, report.Checks["broker"].Status)
+
+	mockProducer.HealthErr = errors.New("nats: connection is RECONNECTING")
+
+	code, report = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, services.HealthStatusUnavailable, report.Status)
+	assert.Equal(t, "nats: connection is RECONNECTING", report.Checks["broker"].Error)
+
+	// Liveness does not depend on the broker.
+	code, report = get("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, services.HealthStatusOK, report.Status)
+}
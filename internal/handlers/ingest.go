@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metricsTopic is the API topic served by the legacy /metrics routes.
+const metricsTopic = "metrics"
+
+// PostIngest is the handler for posting a record to the topic named in the URL.
+func PostIngest(c *gin.Context, registry *registries.ServerAppRegistry) {
+	postRecord(c, registry, c.Param("topic"))
+}
+
+// PostIngestBatch is the bulk variant of PostIngest; see PostMetricsBatch for
+// the accepted body formats.
+func PostIngestBatch(c *gin.Context, registry *registries.ServerAppRegistry) {
+	postBatch(c, registry, c.Param("topic"))
+}
+
+// resolveSubject maps an API topic to its broker subject, responding with 400
+// for malformed topic names and 404 for topics outside the allow-list.
+func resolveSubject(c *gin.Context, registry *registries.ServerAppRegistry, topic string) (string, bool) {
+	subject, err := registry.Topics.Resolve(topic)
+	switch {
+	case err == nil:
+		return subject, true
+	case errors.Is(err, services.ErrUnknownTopic):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "topic": topic})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "topic": topic})
+	}
+	return "", false
+}
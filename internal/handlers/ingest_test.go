@@ -0,0 +1,141 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"play.ground/generic-data-collector/internal/handlers"
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIngestRouter(t *testing.T) (*gin.Engine, *services.MockProducer) {
+	gin.SetMode(gin.TestMode)
+
+	registry := registries.NewMockServerAppRegistry()
+	registry.SyncPublish = true
+	topics, err := services.NewTopicRouter(map[string]string{
+		"metrics": "metrics",
+		"events":  "ingest.events",
+	})
+	require.NoError(t, err)
+	registry.Topics = topics
+
+	router := gin.New()
+	router.POST("/api/v1/ingest/:topic", func(c *gin.Context) {
+		handlers.PostIngest(c, registry)
+	})
+	router.POST("/api/v1/ingest/:topic/batch", func(c *gin.Context) {
+		handlers.PostIngestBatch(c, registry)
+	})
+	return router, registry.Producer.(*services.MockProducer)
+}
+
+func TestPostIngest_RoutesTopicToSubject(t *testing.T) {
+	router, mockProducer := newIngestRouter(t)
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/ingest/events", bytes.NewBufferString(`{"type": "login"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	published := mockProducer.Published()
+	require.Len(t, published, 1)
+	assert.Equal(t, "ingest.events", published[0].Topic)
+}
+
+func TestPostIngestBatch_RoutesTopicToSubject(t *testing.T) {
+	router, mockProducer := newIngestRouter(t)
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/ingest/events/batch", bytes.NewBufferString(`[{"a": 1}, {"a": 2}]`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	published := mockProducer.Published()
+	require.Len(t, published, 2)
+	assert.Equal(t, "ingest.events", published[1].Topic)
+}
+
+func TestPostIngest_RejectsTopics(t *testing.T) {
+	router, mockProducer := newIngestRouter(t)
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/api/v1/ingest/logs", http.StatusNotFound},
+		{"/api/v1/ingest/Events", http.StatusBadRequest},
+		{"/api/v1/ingest/ingest.events", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, tt.path, bytes.NewBufferString(`{"a": 1}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.status, w.Code, tt.path)
+	}
+	assert.Empty(t, mockProducer.Published())
+}
+
+func TestPostIngest_SchemaViolations(t *testing.T) {
+	dir := t.TempDir()
+	schema := `{"type": "object", "required": ["type"], "properties": {"type": {"type": "string"}}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "events.json"), []byte(schema), 0o644))
+
+	gin.SetMode(gin.TestMode)
+	registry := registries.NewMockServerAppRegistry()
+	topics, err := services.NewTopicRouter(map[string]string{"events": "ingest.events"})
+	require.NoError(t, err)
+	registry.Topics = topics
+	registry.Schemas, err = services.NewSchemaRegistry(dir)
+	require.NoError(t, err)
+	mockProducer := registry.Producer.(*services.MockProducer)
+
+	router := gin.New()
+	router.POST("/api/v1/ingest/:topic", func(c *gin.Context) {
+		handlers.PostIngest(c, registry)
+	})
+	router.POST("/api/v1/ingest/:topic/batch", func(c *gin.Context) {
+		handlers.PostIngestBatch(c, registry)
+	})
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/ingest/events", bytes.NewBufferString(`{"type": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var body struct {
+		Violations []services.Violation `json:"violations"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Violations, 1)
+	assert.Equal(t, "/type", body.Violations[0].Path)
+
+	req, _ = http.NewRequest(http.MethodPost, "/api/v1/ingest/events/batch", bytes.NewBufferString(`[{"type": "ok"}, {}]`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	var report handlers.BatchReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 1, report.Accepted)
+	require.Len(t, report.Results[1].Violations, 1)
+
+	assert.Len(t, mockProducer.Published(), 1)
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"time"
+
+	"play.ground/generic-data-collector/internal/registries"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests that did not match any route.
+const unmatchedRoute = "unmatched"
+
+// Instrument is a middleware that records the count and latency of every
+// request, labelled by route template rather than path.
+func Instrument(c *gin.Context, registry *registries.ServerAppRegistry) {
+	if registry.Metrics == nil {
+		return
+	}
+
+	start := time.Now()
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = unmatchedRoute
+	}
+	registry.Metrics.ObserveHTTPRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+}
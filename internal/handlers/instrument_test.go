@@ -0,0 +1,43 @@
+package handlers_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"play.ground/generic-data-collector/internal/handlers"
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrument_RecordsRequestsByRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry := registries.NewMockServerAppRegistry()
+	registry.SyncPublish = true
+	registry.Metrics = services.NewMetrics()
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) { handlers.Instrument(c, registry) })
+	router.POST("/api/v1/ingest/:topic", func(c *gin.Context) {
+		handlers.PostIngest(c, registry)
+	})
+	router.GET("/metrics", gin.WrapH(registry.Metrics.Handler()))
+
+	for _, path := range []string{"/api/v1/ingest/metrics", "/api/v1/ingest/unknown", "/nowhere"} {
+		req, _ := http.NewRequest(http.MethodPost, path, bytes.NewBufferString(`{"value": 1}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+
+	assert.Contains(t, body, `ingest_http_requests_total{method="POST",route="/api/v1/ingest/:topic",status="202"} 1`)
+	assert.Contains(t, body, `ingest_http_requests_total{method="POST",route="/api/v1/ingest/:topic",status="404"} 1`)
+	assert.Contains(t, body, `ingest_http_requests_total{method="POST",route="unmatched",status="404"} 1`)
+}
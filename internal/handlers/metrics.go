@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 
 	"play.ground/generic-data-collector/internal/registries"
@@ -12,24 +11,45 @@ import (
 
 // PostMetric is the handler for posting a new metric.
 func PostMetric(c *gin.Context, registry *registries.ServerAppRegistry) {
+	postRecord(c, registry, metricsTopic)
+}
+
+// postRecord publishes a single JSON object from the request body to the subject
+// of the given API topic.
+func postRecord(c *gin.Context, registry *registries.ServerAppRegistry, topic string) {
+	subject, ok := resolveSubject(c, registry, topic)
+	if !ok || !authorizeTopic(c, topic) {
+		return
+	}
+
 	var data map[string]interface{}
 	if err := c.ShouldBindJSON(&data); err != nil {
+		if isBodyTooLarge(err) {
+			abortTooLarge(c, registry.MaxBodyBytes)
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if violations := registry.Schemas.Validate(topic, data); len(violations) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":      errSchemaValidation.Error(),
+			"violations": violations,
+		})
+		return
+	}
+
 	payload, err := json.Marshal(data)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create payload"})
 		return
 	}
 
-	// Asynchronously publish the message.
-	go func() {
-		if err := registry.Producer.Publish("metrics", payload); err != nil {
-			log.Printf("Error publishing message: %v", err)
-		}
-	}()
+	if err := publish(c.Request.Context(), registry, subject, payload); err != nil {
+		abortUnavailable(c)
+		return
+	}
 
 	c.JSON(http.StatusAccepted, gin.H{"status": "ok"})
 }
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxBatchRecords caps the number of records accepted in a single bulk request.
+	maxBatchRecords = 10000
+	// maxNDJSONLineSize caps the size of a single NDJSON record.
+	maxNDJSONLineSize = 1 << 20
+
+	ndjsonContentType = "application/x-ndjson"
+
+	recordAccepted = "accepted"
+	recordRejected = "rejected"
+)
+
+var (
+	errTooManyRecords   = fmt.Errorf("batch exceeds the maximum of %d records", maxBatchRecords)
+	errSchemaValidation = errors.New("record does not match the topic schema")
+)
+
+// schemaError is returned by publishRecord for records that violate the topic schema.
+type schemaError struct {
+	violations []services.Violation
+}
+
+func (e *schemaError) Error() string {
+	return errSchemaValidation.Error()
+}
+
+// RecordResult reports the outcome for a single record of a bulk request.
+type RecordResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Violations lists the schema violations of a rejected record.
+	Violations []services.Violation `json:"violations,omitempty"`
+}
+
+// BatchReport is the response body of a bulk ingestion request.
+type BatchReport struct {
+	Accepted int            `json:"accepted"`
+	Rejected int            `json:"rejected"`
+	Results  []RecordResult `json:"results"`
+}
+
+// PostMetricsBatch is the handler for posting many metrics in a single request.
+// The body is either a JSON array of objects or, when the Content-Type is
+// application/x-ndjson, one JSON object per line. Every record is published
+// individually and the response reports which records were accepted.
+func PostMetricsBatch(c *gin.Context, registry *registries.ServerAppRegistry) {
+	postBatch(c, registry, metricsTopic)
+}
+
+// postBatch publishes every record of a bulk request to the subject of the given API topic.
+func postBatch(c *gin.Context, registry *registries.ServerAppRegistry, topic string) {
+	subject, ok := resolveSubject(c, registry, topic)
+	if !ok || !authorizeTopic(c, topic) {
+		return
+	}
+
+	records, err := readBatch(c.Request)
+	if err != nil {
+		if isBodyTooLarge(err) {
+			abortTooLarge(c, registry.MaxBodyBytes)
+			return
+		}
+		status := http.StatusBadRequest
+		if errors.Is(err, errTooManyRecords) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if len(records) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "batch contains no records"})
+		return
+	}
+
+	report := BatchReport{Results: make([]RecordResult, 0, len(records))}
+	for i, record := range records {
+		result := RecordResult{Index: i, Status: recordAccepted}
+		if err := publishRecord(c.Request.Context(), registry, topic, subject, record); err != nil {
+			result.Status = recordRejected
+			result.Error = err.Error()
+			var schemaErr *schemaError
+			if errors.As(err, &schemaErr) {
+				result.Violations = schemaErr.violations
+			}
+			report.Rejected++
+		} else {
+			report.Accepted++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	status := http.StatusAccepted
+	if report.Rejected > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, report)
+}
+
+// publishRecord validates a single raw record against the topic schema and
+// publishes it to the subject.
+func publishRecord(ctx context.Context, registry *registries.ServerAppRegistry, topic, subject string, record json.RawMessage) error {
+	var data map[string]interface{}
+	if err := json.Unmarshal(record, &data); err != nil {
+		return fmt.Errorf("invalid record: %w", err)
+	}
+	if data == nil {
+		return errors.New("invalid record: expected a JSON object")
+	}
+	if violations := registry.Schemas.Validate(topic, data); len(violations) > 0 {
+		return &schemaError{violations: violations}
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return errors.New("failed to create payload")
+	}
+
+	// Records are published inline so that each result reflects the broker's response.
+	ctx, span := startPublishSpan(ctx, subject)
+	header := messageHeader(ctx)
+	if registry.SyncPublish {
+		ctx, cancel := context.WithTimeout(ctx, registry.PublishTimeout)
+		defer cancel()
+		err = registry.Producer.PublishSync(ctx, subject, payload, header)
+	} else {
+		err = registry.Producer.Publish(subject, payload, header)
+	}
+	endSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("failed to publish: %w", err)
+	}
+	return nil
+}
+
+// readBatch splits the request body into raw records according to its Content-Type.
+func readBatch(r *http.Request) ([]json.RawMessage, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == ndjsonContentType {
+		return readNDJSON(r.Body)
+	}
+	return readJSONArray(r.Body)
+}
+
+func readJSONArray(body io.Reader) ([]json.RawMessage, error) {
+	dec := json.NewDecoder(body)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON array: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("invalid JSON array: body must be an array of objects")
+	}
+
+	var records []json.RawMessage
+	for dec.More() {
+		if len(records) >= maxBatchRecords {
+			return nil, errTooManyRecords
+		}
+		var record json.RawMessage
+		if err := dec.Decode(&record); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		records = append(records, record)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("invalid JSON array: %w", err)
+	}
+	// Nothing but whitespace may follow the array.
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		return nil, errors.New("invalid JSON array: unexpected data after the array")
+	}
+	return records, nil
+}
+
+func readNDJSON(body io.Reader) ([]json.RawMessage, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLineSize)
+
+	var records []json.RawMessage
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(records) >= maxBatchRecords {
+			return nil, errTooManyRecords
+		}
+		// The scanner reuses its buffer, so each record needs its own copy.
+		records = append(records, append(json.RawMessage(nil), line...))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid NDJSON body: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,106 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"play.ground/generic-data-collector/internal/handlers"
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchRouter(registry *registries.ServerAppRegistry) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/metrics/batch", func(c *gin.Context) {
+		handlers.PostMetricsBatch(c, registry)
+	})
+	return router
+}
+
+func postBatch(router *gin.Engine, contentType, body string) (*httptest.ResponseRecorder, handlers.BatchReport) {
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/metrics/batch", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", contentType)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var report handlers.BatchReport
+	_ = json.Unmarshal(w.Body.Bytes(), &report)
+	return w, report
+}
+
+func TestPostMetricsBatch_JSONArray(t *testing.T) {
+	registry := registries.NewMockServerAppRegistry()
+	mockProducer := registry.Producer.(*services.MockProducer)
+	router := newBatchRouter(registry)
+
+	w, report := postBatch(router, "application/json", `[{"value": 1}, {"value": 2}]`)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, 2, report.Accepted)
+	assert.Equal(t, 0, report.Rejected)
+
+	published := mockProducer.Published()
+	require.Len(t, published, 2)
+	assert.Equal(t, "metrics", published[0].Topic)
+	assert.JSONEq(t, `{"value": 1}`, string(published[0].Data))
+	assert.JSONEq(t, `{"value": 2}`, string(published[1].Data))
+}
+
+func TestPostMetricsBatch_NDJSONPartialFailure(t *testing.T) {
+	registry := registries.NewMockServerAppRegistry()
+	mockProducer := registry.Producer.(*services.MockProducer)
+	router := newBatchRouter(registry)
+
+	body := "{\"value\": 1}\n\n{not json}\n[1, 2]\n{\"value\": 4}\n"
+	w, report := postBatch(router, "application/x-ndjson; charset=utf-8", body)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Equal(t, 2, report.Accepted)
+	assert.Equal(t, 2, report.Rejected)
+	require.Len(t, report.Results, 4)
+	assert.Equal(t, "accepted", report.Results[0].Status)
+	assert.Equal(t, "rejected", report.Results[1].Status)
+	assert.NotEmpty(t, report.Results[1].Error)
+	assert.Equal(t, "rejected", report.Results[2].Status)
+	assert.Equal(t, "accepted", report.Results[3].Status)
+
+	assert.Len(t, mockProducer.Published(), 2)
+}
+
+func TestPostMetricsBatch_PublishFailure(t *testing.T) {
+	registry := registries.NewMockServerAppRegistry()
+	registry.Producer.(*services.MockProducer).PublishErr = errors.New("nats: connection closed")
+	router := newBatchRouter(registry)
+
+	w, report := postBatch(router, "application/json", `[{"value": 1}]`)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Equal(t, 0, report.Accepted)
+	assert.Equal(t, 1, report.Rejected)
+	assert.Contains(t, report.Results[0].Error, "connection closed")
+}
+
+func TestPostMetricsBatch_InvalidBody(t *testing.T) {
+	router := newBatchRouter(registries.NewMockServerAppRegistry())
+
+	w, _ := postBatch(router, "application/json", `{"value": 1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = postBatch(router, "application/json", `[]`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	for _, body := range []string{`[{"value": 1}] garbage`, `[{"value": 1}][{"value": 2}]`} {
+		w, _ = postBatch(router, "application/json", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
@@ -2,7 +2,9 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -77,4 +79,94 @@ func TestPostMetric_InvalidJSON(t *testing.T) {
 
 	// Should return 400 Bad Request
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-}
\ No newline at end of file
+}
+
+func TestPostMetric_SyncPublish(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry := registries.NewMockServerAppRegistry()
+	registry.SyncPublish = true
+	mockProducer := registry.Producer.(*services.MockProducer)
+
+	router := gin.New()
+	router.POST("/api/v1/metrics", func(c *gin.Context) {
+		handlers.PostMetric(c, registry)
+	})
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/metrics", bytes.NewBufferString(`{"value": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// The message must already be published when the response is returned.
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Len(t, mockProducer.Published(), 1)
+}
+
+func TestPostMetric_SyncPublishFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		configure func(*services.MockProducer, *registries.ServerAppRegistry)
+	}{
+		{
+			name: "publish error",
+			configure: func(p *services.MockProducer, _ *registries.ServerAppRegistry) {
+				p.PublishErr = errors.New("nats: connection closed")
+			},
+		},
+		{
+			name: "confirmation timeout",
+			configure: func(p *services.MockProducer, r *registries.ServerAppRegistry) {
+				p.SyncDelay = time.Second
+				r.PublishTimeout = 10 * time.Millisecond
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := registries.NewMockServerAppRegistry()
+			registry.SyncPublish = true
+			tt.configure(registry.Producer.(*services.MockProducer), registry)
+
+			router := gin.New()
+			router.POST("/api/v1/metrics", func(c *gin.Context) {
+				handlers.PostMetric(c, registry)
+			})
+
+			req, _ := http.NewRequest(http.MethodPost, "/api/v1/metrics", bytes.NewBufferString(`{"value": 1}`))
+			req.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+			assert.Equal(t, "5", w.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestPostMetric_AsyncPublishIsTracked(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry := registries.NewMockServerAppRegistry()
+	mockProducer := registry.Producer.(*services.MockProducer)
+
+	router := gin.New()
+	router.POST("/api/v1/metrics", func(c *gin.Context) {
+		handlers.PostMetric(c, registry)
+	})
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/metrics", bytes.NewBufferString(`{"value": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// Shutdown waits for background publishes before closing the producer.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, registry.Publishes.Wait(ctx))
+	assert.Len(t, mockProducer.Published(), 1)
+}
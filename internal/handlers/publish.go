@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// publishRetryAfter is the delay, in seconds, suggested to clients when a publish fails.
+const publishRetryAfter = 5
+
+// publish sends the payload to the topic according to the registry's publish mode.
+// In synchronous mode it blocks until the broker confirms receipt or the publish
+// timeout elapses; otherwise it publishes in the background and returns immediately.
+// The message carries the identity of the client stored in ctx, if any, and
+// the trace context of the publish span. Failures are logged with the request ID.
+func publish(ctx context.Context, registry *registries.ServerAppRegistry, topic string, payload []byte) error {
+	logger := services.LoggerFromContext(ctx, registry.Logger)
+	ctx, span := startPublishSpan(ctx, topic)
+	header := messageHeader(ctx)
+	if !registry.SyncPublish {
+		registry.Publishes.Go(func() {
+			err := registry.Producer.Publish(topic, payload, header)
+			if err != nil {
+				logger.Error("Failed to publish message", "subject", topic, "error", err)
+			}
+			endSpan(span, err)
+		})
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, registry.PublishTimeout)
+	defer cancel()
+
+	err := registry.Producer.PublishSync(ctx, topic, payload, header)
+	if err != nil {
+		logger.Error("Failed to publish message", "subject", topic, "error", err)
+	}
+	endSpan(span, err)
+	return err
+}
+
+// abortUnavailable responds with 503 and a Retry-After hint after a failed publish.
+func abortUnavailable(c *gin.Context) {
+	c.Header("Retry-After", strconv.Itoa(publishRetryAfter))
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"error":       "failed to publish message",
+		"retry_after": publishRetryAfter,
+	})
+}
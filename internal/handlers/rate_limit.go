@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitIP is a middleware that enforces the request budgets of the
+// client IP address before the request is authenticated, so that requests
+// with unknown API keys are limited too.
+func RateLimitIP(c *gin.Context, registry *registries.ServerAppRegistry) {
+	limiter := registry.IPRateLimiter
+	if limiter == nil {
+		return
+	}
+	if err := limiter.Allow("ip:"+c.ClientIP(), "", 0); err != nil {
+		abortRateLimited(c, err)
+	}
+}
+
+// RateLimit is a middleware that enforces the request and byte rate limits and
+// the daily quotas of the client, responding with 429 and a Retry-After header
+// when they are exceeded. Authenticated requests are limited per client, others
+// per IP address, so it must run after Authenticate. Bytes are counted once
+// decoded, so it must run after Decompress too.
+func RateLimit(c *gin.Context, registry *registries.ServerAppRegistry) {
+	limiter := registry.RateLimiter
+	if limiter == nil {
+		return
+	}
+
+	key, clientID := "ip:"+c.ClientIP(), ""
+	if client, ok := services.ClientFromContext(c.Request.Context()); ok {
+		key, clientID = "client:"+client.ID, client.ID
+	}
+
+	size := c.Request.ContentLength
+	if size < 0 {
+		size = 0
+	}
+	if err := limiter.Allow(key, clientID, size); err != nil {
+		abortRateLimited(c, err)
+		return
+	}
+
+	// Bodies of unknown length, such as chunked or compressed ones, are
+	// charged once read.
+	if c.Request.ContentLength < 0 && c.Request.Body != nil {
+		body := &countingReader{ReadCloser: c.Request.Body}
+		c.Request.Body = body
+		c.Next()
+		limiter.ChargeBytes(key, clientID, body.n)
+	}
+}
+
+// abortRateLimited responds with 429 and a Retry-After header to a request
+// exceeding its limits.
+func abortRateLimited(c *gin.Context, err error) {
+	var limitErr *services.RateLimitError
+	if !errors.As(err, &limitErr) {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	retryAfter := int(math.Ceil(limitErr.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error":       limitErr.Reason,
+		"retry_after": retryAfter,
+	})
+}
+
+// countingReader counts the bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
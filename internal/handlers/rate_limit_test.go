@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"play.ground/generic-data-collector/internal/handlers"
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRateLimitedRouter(t *testing.T, limit services.RateLimit) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	registry := registries.NewMockServerAppRegistry()
+	registry.SyncPublish = true
+	registry.RateLimiter = services.NewRateLimiter(limit, nil)
+
+	withRegistry := func(handler func(*gin.Context, *registries.ServerAppRegistry)) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			handler(c, registry)
+		}
+	}
+	router := gin.New()
+	v1 := router.Group("/api/v1")
+	v1.Use(withRegistry(handlers.Authenticate), withRegistry(handlers.Decompress), withRegistry(handlers.RateLimit))
+	v1.POST("/metrics", withRegistry(handlers.PostMetric))
+	return router
+}
+
+func postMetric(router *gin.Engine, body io.Reader) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/metrics", body)
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "10.0.0.1:1234"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit_TooManyRequests(t *testing.T) {
+	router := newRateLimitedRouter(t, services.RateLimit{RequestsPerSecond: 0.1, RequestBurst: 1})
+
+	w := postMetric(router, bytes.NewBufferString(`{"value": 1}`))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	w = postMetric(router, bytes.NewBufferString(`{"value": 2}`))
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "request rate limit exceeded")
+}
+
+func TestRateLimit_ChargesBodiesOfUnknownLength(t *testing.T) {
+	router := newRateLimitedRouter(t, services.RateLimit{BytesPerSecond: 1, ByteBurst: 20})
+
+	// A chunked upload has no Content-Length.
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/metrics", strings.NewReader(`{"value": "0123456789"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = -1
+	req.RemoteAddr = "10.0.0.1:1234"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	w = postMetric(router, bytes.NewBufferString(`{"v": 1}`))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestRateLimit_ChargesDecompressedBytes(t *testing.T) {
+	router := newRateLimitedRouter(t, services.RateLimit{BytesPerSecond: 1, ByteBurst: 100})
+
+	// The body is far smaller compressed than the byte burst, but not once decoded.
+	record := []byte(`{"value": "` + strings.Repeat("a", 500) + `"}`)
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/metrics", bytes.NewReader(compress(t, "gzip", record)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.RemoteAddr = "10.0.0.1:1234"
+	require.Less(t, req.ContentLength, int64(100))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	w = postMetric(router, bytes.NewBufferString(`{"v": 1}`))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestRateLimitIP_LimitsUnauthenticatedRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	registry := registries.NewMockServerAppRegistry()
+	var err error
+	registry.APIKeys, err = services.NewAPIKeyStore([]services.APIKey{
+		{Client: "dashboard", SHA256: services.HashAPIKey("dashboard-key"), Topics: []string{"*"}},
+	})
+	require.NoError(t, err)
+	registry.IPRateLimiter = services.NewRateLimiter(services.RateLimit{RequestsPerSecond: 0.1, RequestBurst: 1}, nil)
+
+	withRegistry := func(handler func(*gin.Context, *registries.ServerAppRegistry)) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			handler(c, registry)
+		}
+	}
+	router := gin.New()
+	v1 := router.Group("/api/v1")
+	v1.Use(withRegistry(handlers.RateLimitIP), withRegistry(handlers.Authenticate))
+	v1.POST("/metrics", withRegistry(handlers.PostMetric))
+
+	send := func() int {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/metrics", bytes.NewBufferString(`{"value": 1}`))
+		req.Header.Set("X-Api-Key", "wrong")
+		req.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusUnauthorized, send())
+	assert.Equal(t, http.StatusTooManyRequests, send())
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	// requestIDKey is the gin context key of the request ID.
+	requestIDKey = "request_id"
+	// maxRequestIDLength bounds the length of request IDs accepted from clients.
+	maxRequestIDLength = 128
+)
+
+// RequestID is a middleware that tags the request with an ID, taken from the
+// X-Request-ID header when the client or a proxy sent a valid one, and echoes
+// it in the response. Handlers log through a logger carrying the ID, stored in
+// the request context.
+func RequestID(c *gin.Context, registry *registries.ServerAppRegistry) {
+	id := c.GetHeader(requestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	c.Set(requestIDKey, id)
+	c.Header(requestIDHeader, id)
+
+	logger := registry.Logger.With(requestIDKey, id)
+	c.Request = c.Request.WithContext(services.ContextWithLogger(c.Request.Context(), logger))
+}
+
+// LogRequests returns a middleware that logs every request once it has been
+// served: at error level for server errors and at info level otherwise.
+// Request bodies are never logged.
+func LogRequests(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		if !logger.Enabled(c.Request.Context(), level) {
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		logger.LogAttrs(c.Request.Context(), level, "Request served",
+			slog.String(requestIDKey, c.GetString(requestIDKey)),
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("response_bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
+
+// validRequestID reports whether a request ID sent by a client is safe to echo
+// and log: non-empty, bounded and made of printable ASCII without spaces.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on supported platforms.
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,102 @@
+package handlers_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"play.ground/generic-data-collector/internal/handlers"
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLoggedRouter(t *testing.T, registry *registries.ServerAppRegistry, out *bytes.Buffer) *gin.Engine {
+	t.Helper()
+	logging, err := services.NewLogging(services.LogConfig{Level: "info"}, out)
+	require.NoError(t, err)
+	registry.Logging = logging
+	registry.Logger = logging.Logger(services.LogComponentServer)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(
+		func(c *gin.Context) { handlers.RequestID(c, registry) },
+		handlers.LogRequests(logging.Logger(services.LogComponentHTTP)),
+	)
+	router.POST("/api/v1/metrics", func(c *gin.Context) {
+		handlers.PostMetric(c, registry)
+	})
+	return router
+}
+
+func TestRequestID_GeneratedAndLogged(t *testing.T) {
+	var out bytes.Buffer
+	registry := registries.NewMockServerAppRegistry()
+	registry.SyncPublish = true
+	router := newLoggedRouter(t, registry, &out)
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/metrics", bytes.NewBufferString(`{"secret": "s3cr3t"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	id := w.Header().Get("X-Request-ID")
+	assert.Len(t, id, 32)
+	assert.Contains(t, out.String(), "request_id="+id)
+	assert.Contains(t, out.String(), "route=/api/v1/metrics status=202")
+	assert.NotContains(t, out.String(), "s3cr3t", "request bodies must not be logged")
+}
+
+func TestRequestID_ClientIDIsKeptWhenValid(t *testing.T) {
+	var out bytes.Buffer
+	registry := registries.NewMockServerAppRegistry()
+	registry.SyncPublish = true
+	router := newLoggedRouter(t, registry, &out)
+
+	for _, tc := range []struct {
+		sent string
+		kept bool
+	}{
+		{sent: "edge-7f3a", kept: true},
+		{sent: "bad id\nwith newline", kept: false},
+		{sent: strings.Repeat("x", 200), kept: false},
+	} {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/metrics", bytes.NewBufferString(`{"value": 1}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Request-ID", tc.sent)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if tc.kept {
+			assert.Equal(t, tc.sent, w.Header().Get("X-Request-ID"))
+		} else {
+			assert.NotEqual(t, tc.sent, w.Header().Get("X-Request-ID"))
+			assert.NotEmpty(t, w.Header().Get("X-Request-ID"))
+		}
+	}
+}
+
+func TestPublish_FailureIsLoggedWithRequestID(t *testing.T) {
+	var out bytes.Buffer
+	registry := registries.NewMockServerAppRegistry()
+	registry.SyncPublish = true
+	registry.Producer.(*services.MockProducer).PublishErr = errors.New("broker down")
+	router := newLoggedRouter(t, registry, &out)
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/metrics", bytes.NewBufferString(`{"value": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", "req-42")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, out.String(), `msg="Failed to publish message" component=server request_id=req-42 subject=metrics`)
+}
@@ -0,0 +1,66 @@
+package handlers_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"play.ground/generic-data-collector/internal/handlers"
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTrace_PropagatesToPublishedMessages(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	gin.SetMode(gin.TestMode)
+	registry := registries.NewMockServerAppRegistry()
+	registry.SyncPublish = true
+	mockProducer := registry.Producer.(*services.MockProducer)
+
+	router := gin.New()
+	router.Use(handlers.Trace)
+	router.POST("/api/v1/metrics", func(c *gin.Context) {
+		handlers.PostMetric(c, registry)
+	})
+
+	const clientTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/metrics", bytes.NewBufferString(`{"value": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-"+clientTraceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	server, publish := spans["POST /api/v1/metrics"], spans["publish metrics"]
+	require.NotNil(t, server)
+	require.NotNil(t, publish)
+
+	// Both spans continue the client's trace, and the message carries the
+	// context of the publish span.
+	assert.Equal(t, clientTraceID, server.SpanContext().TraceID().String())
+	assert.Equal(t, server.SpanContext().SpanID(), publish.Parent().SpanID())
+	assert.Equal(t, trace.SpanKindProducer, publish.SpanKind())
+
+	published := mockProducer.Published()
+	require.Len(t, published, 1)
+	assert.Equal(t,
+		"00-"+clientTraceID+"-"+publish.SpanContext().SpanID().String()+"-01",
+		published[0].Header["traceparent"])
+}
@@ -1,9 +1,35 @@
 package interfaces
 
+import (
+	"context"
+	"time"
+)
+
 type (
+	// Message is a message received from a pub/sub system. Brokers without
+	// acknowledgement support implement the acknowledgement methods as no-ops.
 	Message interface {
 		// Data returns the message payload.
 		Data() []byte
+		// Subject returns the subject/topic the message was published to, or an
+		// empty string if it is unknown.
+		Subject() string
+		// Header returns the metadata published with the message, or nil if
+		// there is none.
+		Header() Header
+		// Ack tells the broker that the message has been processed successfully.
+		Ack() error
+		// Nak tells the broker that processing failed and the message should be
+		// redelivered after the given delay.
+		Nak(delay time.Duration) error
+		// Term tells the broker to never redeliver the message.
+		Term() error
+		// InProgress tells the broker that the message is still being worked on,
+		// resetting its redelivery timer.
+		InProgress() error
+		// NumDelivered returns how many times the message has been delivered,
+		// starting at 1.
+		NumDelivered() uint64
 	}
 
 	// Consumer defines the interface for receiving messages from a pub/sub system.
@@ -11,6 +37,15 @@ type (
 		// Subscribe starts listening to a given channel and returns a Go channel
 		// from which messages can be read.
 		Subscribe(topic string) (<-chan Message, error)
+		// QueueSubscribe is like Subscribe, but joins the named queue group:
+		// each message is delivered to only one of the group's subscribers,
+		// which lets several workers share the load of a topic.
+		QueueSubscribe(topic, queue string) (<-chan Message, error)
+		// Unsubscribe stops the subscriptions to the topic and closes their
+		// channels; messages already buffered in them can still be read.
+		Unsubscribe(topic string) error
+		// Health reports whether the consumer is connected to the broker.
+		Health(ctx context.Context) error
 		// Close stops the consumer and cleans up any underlying resources.
 		Close() error
 	}
@@ -0,0 +1,9 @@
+package interfaces
+
+import "context"
+
+// HealthChecker is implemented by dependencies that can report their health.
+type HealthChecker interface {
+	// Health returns nil if the dependency is able to serve requests.
+	Health(ctx context.Context) error
+}
@@ -1,9 +1,23 @@
 package interfaces
 
+import "context"
+
+// HeaderClientID is the message header carrying the ID of the API client that
+// sent the record.
+const HeaderClientID = "Client-Id"
+
+// Header holds metadata published alongside a message payload.
+type Header map[string]string
+
 // Producer defines the interface for sending messages to a pub/sub system.
 type Producer interface {
-	// Publish sends a message to a specific channel/topic.
-	Publish(topic string, message []byte) error
+	// Publish sends a message to a specific channel/topic. The header may be nil.
+	Publish(topic string, message []byte, header Header) error
+	// PublishSync sends a message and waits until the broker has confirmed
+	// receipt or the context is done.
+	PublishSync(ctx context.Context, topic string, message []byte, header Header) error
+	// Health reports whether the producer is connected to the broker.
+	Health(ctx context.Context) error
 	// Close cleans up any underlying resources.
 	Close() error
 }
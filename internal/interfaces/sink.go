@@ -0,0 +1,14 @@
+package interfaces
+
+import "context"
+
+// Sink defines the interface for delivering batches of records to their destination.
+type Sink interface {
+	// Write delivers a batch of record payloads. A nil error means the whole
+	// batch has been stored and its messages may be acknowledged.
+	Write(ctx context.Context, batch [][]byte) error
+	// Health reports whether the sink is currently able to accept writes.
+	Health(ctx context.Context) error
+	// Close flushes any buffered data and cleans up underlying resources.
+	Close() error
+}
@@ -0,0 +1,27 @@
+// Package natstest runs NATS servers for tests, so that the NATS and JetStream
+// producers and consumers can be tested without an external server.
+package natstest
+
+import (
+	"testing"
+
+	"play.ground/generic-data-collector/internal/services"
+)
+
+// Run starts a NATS server with JetStream on a random port, stores its streams
+// in a temporary directory, and returns its client URL. The server is shut
+// down when the test ends.
+func Run(t testing.TB) string {
+	t.Helper()
+	server, err := services.StartEmbeddedNATS(services.EmbeddedNATSConfig{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("failed to start NATS server: %v", err)
+	}
+	t.Cleanup(server.Shutdown)
+	return server.ClientURL()
+}
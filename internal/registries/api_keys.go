@@ -0,0 +1,41 @@
+package registries
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+	"golang.org/x/exp/slog"
+	"play.ground/generic-data-collector/initializers"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+// getAPIKeys builds the API key store from the API_KEYS list and from the
+// API_KEYS list of the file named by API_KEYS_FILE. Without any keys,
+// authentication is disabled and nil is returned.
+func getAPIKeys(settings initializers.ServerConfig, logger *slog.Logger) (*services.APIKeyStore, error) {
+	keys := append([]services.APIKey(nil), settings.APIKeys...)
+
+	if path := settings.APIKeysFile; path != "" {
+		file := viper.New()
+		file.SetConfigFile(path)
+		if err := file.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read API_KEYS_FILE: %w", err)
+		}
+		var fileKeys []services.APIKey
+		if err := file.UnmarshalKey("API_KEYS", &fileKeys); err != nil {
+			return nil, fmt.Errorf("invalid API_KEYS in %s: %w", path, err)
+		}
+		keys = append(keys, fileKeys...)
+	}
+
+	if len(keys) == 0 {
+		logger.Warn("No API keys configured, the API accepts unauthenticated requests")
+		return nil, nil
+	}
+
+	store, err := services.NewAPIKeyStore(keys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API keys: %w", err)
+	}
+	return store, nil
+}
@@ -0,0 +1,198 @@
+package registries
+
+import (
+	"fmt"
+	"sync"
+
+	"play.ground/generic-data-collector/initializers"
+	"play.ground/generic-data-collector/internal/interfaces"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+var (
+	memoryBrokerMu   sync.Mutex
+	memoryBroker     *services.MemoryBroker
+	memoryBrokerRefs int
+)
+
+// sharedMemoryBroker is a reference to the memory broker of the process.
+// Closing it gives up the reference; the broker itself is closed once no
+// reference is left.
+type sharedMemoryBroker struct {
+	*services.MemoryBroker
+	release sync.Once
+}
+
+// acquireMemoryBroker returns a reference to the memory broker of the process,
+// creating the broker if needed, so that the server and worker registries of a
+// single process exchange messages. The settings of the registry creating the
+// broker apply.
+func acquireMemoryBroker(settings initializers.MemoryConfig) (*sharedMemoryBroker, error) {
+	memoryBrokerMu.Lock()
+	defer memoryBrokerMu.Unlock()
+
+	if memoryBroker == nil {
+		broker, err := services.NewMemoryBroker(memoryBrokerConfig(settings))
+		if err != nil {
+			return nil, err
+		}
+		memoryBroker = broker
+	}
+	memoryBrokerRefs++
+	return &sharedMemoryBroker{MemoryBroker: memoryBroker}, nil
+}
+
+// Close gives up the reference, closing the broker if it was the last one.
+// Closing a reference again does nothing.
+func (b *sharedMemoryBroker) Close() error {
+	var err error
+	b.release.Do(func() {
+		memoryBrokerMu.Lock()
+		defer memoryBrokerMu.Unlock()
+
+		memoryBrokerRefs--
+		if memoryBrokerRefs == 0 {
+			err = memoryBroker.Close()
+			memoryBroker = nil
+		}
+	})
+	return err
+}
+
+var (
+	embeddedNATSMu   sync.Mutex
+	embeddedNATS     *services.EmbeddedNATS
+	embeddedNATSRefs int
+)
+
+// startEmbeddedNATS starts the embedded NATS server of the process when
+// NATS_EMBEDDED is set, and returns the broker settings pointing at it. The
+// server is shared by the registries of a single process; each successful call
+// takes a reference that releaseEmbeddedNATS gives up.
+func startEmbeddedNATS(settings initializers.BrokerConfig) (initializers.BrokerConfig, *services.EmbeddedNATS, error) {
+	if !settings.Embedded.Enabled {
+		return settings, nil, nil
+	}
+	embeddedNATSMu.Lock()
+	defer embeddedNATSMu.Unlock()
+
+	if embeddedNATS == nil {
+		server, err := services.StartEmbeddedNATS(embeddedNATSConfig(settings))
+		if err != nil {
+			return settings, nil, err
+		}
+		embeddedNATS = server
+	}
+	embeddedNATSRefs++
+	settings.NATSURL = embeddedNATS.ClientURL()
+	return settings, embeddedNATS, nil
+}
+
+// releaseEmbeddedNATS gives up a reference taken by startEmbeddedNATS, and
+// shuts the server down once no registry uses it. Registries failing to
+// initialize call it; processes shut the server down directly on exit.
+func releaseEmbeddedNATS() {
+	embeddedNATSMu.Lock()
+	defer embeddedNATSMu.Unlock()
+
+	embeddedNATSRefs--
+	if embeddedNATSRefs == 0 && embeddedNATS != nil {
+		embeddedNATS.Shutdown()
+		embeddedNATS = nil
+	}
+}
+
+// newProducer creates a producer for the configured broker.
+func newProducer(settings initializers.BrokerConfig) (interfaces.Producer, error) {
+	var producer interfaces.Producer
+	var err error
+	switch settings.Type {
+	case initializers.BrokerJetStream:
+		producer, err = services.NewJetStreamProducer(settings.NATSURL, jetStreamConfig(settings.JetStream))
+	case initializers.BrokerMemory:
+		producer, err = acquireMemoryBroker(settings.Memory)
+	case initializers.BrokerRedis:
+		producer, err = services.NewRedisProducer(redisConfig(settings.Redis))
+	default:
+		producer, err = services.NewNATSProducer(settings.NATSURL)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s producer: %w", settings.Type, err)
+	}
+	return producer, nil
+}
+
+// newConsumer creates a consumer for the configured broker, receiving what
+// the producers of newProducer publish.
+func newConsumer(settings initializers.BrokerConfig) (interfaces.Consumer, error) {
+	var consumer interfaces.Consumer
+	var err error
+	switch settings.Type {
+	case initializers.BrokerJetStream:
+		consumer, err = services.NewJetStreamConsumer(settings.NATSURL, jetStreamConfig(settings.JetStream))
+	case initializers.BrokerMemory:
+		consumer, err = acquireMemoryBroker(settings.Memory)
+	case initializers.BrokerRedis:
+		consumer, err = services.NewRedisConsumer(redisConfig(settings.Redis))
+	default:
+		consumer, err = services.NewNATSConsumer(settings.NATSURL)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s consumer: %w", settings.Type, err)
+	}
+	return consumer, nil
+}
+
+// newDeadLetterProducer creates the producer used to publish dead letters. With
+// JetStream, dead letters are kept in their own stream so that they outlive the
+// retention limits of the ingestion stream.
+func newDeadLetterProducer(broker initializers.BrokerConfig, deadLetter initializers.DeadLetterConfig) (interfaces.Producer, error) {
+	if broker.Type != initializers.BrokerJetStream {
+		return newProducer(broker)
+	}
+
+	cfg := jetStreamConfig(broker.JetStream)
+	cfg.Stream = deadLetter.Stream
+	cfg.Subjects = []string{deadLetter.Subject}
+	cfg.MaxAge = deadLetter.MaxAge
+	return services.NewJetStreamProducer(broker.NATSURL, cfg)
+}
+
+// jetStreamConfig returns the description of the stream and of its durable
+// consumer.
+func jetStreamConfig(settings initializers.JetStreamConfig) services.JetStreamConfig {
+	return services.JetStreamConfig{
+		Stream:        settings.Stream,
+		Subjects:      settings.Subjects,
+		Retention:     settings.Retention,
+		MaxAge:        settings.MaxAge,
+		MaxBytes:      settings.MaxBytes,
+		Replicas:      settings.Replicas,
+		AckTimeout:    settings.AckTimeout,
+		Durable:       settings.Durable,
+		AckWait:       settings.AckWait,
+		MaxDeliver:    settings.MaxDeliver,
+		MaxAckPending: settings.MaxAckPending,
+	}
+}
+
+// redisConfig returns the settings of the Redis Streams broker.
+func redisConfig(settings initializers.RedisConfig) services.RedisConfig {
+	return services.RedisConfig{URL: settings.URL, MaxLen: settings.MaxLen, ClaimMinIdle: settings.ClaimMinIdle}
+}
+
+// memoryBrokerConfig returns the settings of the memory broker.
+func memoryBrokerConfig(settings initializers.MemoryConfig) services.MemoryBrokerConfig {
+	return services.MemoryBrokerConfig{BufferSize: settings.BufferSize, Overflow: settings.Overflow}
+}
+
+// embeddedNATSConfig returns the settings of the embedded NATS server, which
+// runs JetStream for the jetstream broker.
+func embeddedNATSConfig(settings initializers.BrokerConfig) services.EmbeddedNATSConfig {
+	return services.EmbeddedNATSConfig{
+		Host:      settings.Embedded.Host,
+		Port:      settings.Embedded.Port,
+		JetStream: settings.Type == initializers.BrokerJetStream,
+		StoreDir:  settings.Embedded.StoreDir,
+	}
+}
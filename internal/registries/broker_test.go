@@ -0,0 +1,131 @@
+package registries_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"play.ground/generic-data-collector/initializers"
+	"play.ground/generic-data-collector/internal/registries"
+)
+
+func TestNewWorkerAppRegistry_ReleasesEmbeddedNATSOnFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+	// The sink directory cannot be created where a file stands.
+	blocker := filepath.Join(dir, "blocker")
+	require.NoError(t, os.WriteFile(blocker, nil, 0o644))
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(
+		"NATS_EMBEDDED: true\n"+
+			"NATS_EMBEDDED_PORT: %d\n"+
+			"SINK: file\n"+
+			"SINK_FILE_PATH: %s\n", port, filepath.Join(blocker, "records.ndjson"))), 0o644))
+
+	config, err := initializers.NewConfig(path)
+	require.NoError(t, err)
+	settings, err := initializers.LoadWorkerConfig(config)
+	require.NoError(t, err)
+
+	// The sink fails after the embedded server has started, which must
+	// then be shut down, freeing its port.
+	_, err = registries.NewWorkerAppRegistry(config, settings)
+	require.Error(t, err)
+	listener, err = net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	listener.Close()
+}
+
+func TestRegistries_EmbeddedNATS(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"BROKER: jetstream\n"+
+			"NATS_URL: nats://unreachable:4222\n"+
+			"NATS_EMBEDDED: true\n"+
+			"NATS_EMBEDDED_PORT: -1\n"+
+			"NATS_EMBEDDED_STORE_DIR: "+filepath.Join(dir, "jetstream")+"\n"+
+			"SINK: log\n"), 0o644))
+
+	config, err := initializers.NewConfig(path)
+	require.NoError(t, err)
+	serverSettings, err := initializers.LoadServerConfig(config)
+	require.NoError(t, err)
+	workerSettings, err := initializers.LoadWorkerConfig(config)
+	require.NoError(t, err)
+
+	server, err := registries.NewServerAppRegistry(config, serverSettings)
+	require.NoError(t, err)
+	worker, err := registries.NewWorkerAppRegistry(config, workerSettings)
+	require.NoError(t, err)
+	defer server.EmbeddedNATS.Shutdown()
+	defer server.Producer.Close()
+	defer worker.Consumer.Close()
+
+	// Both registries connect to the same server, and the startup settings
+	// keep the configured URL.
+	require.NotNil(t, server.EmbeddedNATS)
+	assert.Same(t, server.EmbeddedNATS, worker.EmbeddedNATS)
+	assert.Equal(t, "nats://unreachable:4222", server.Settings.Broker.NATSURL)
+
+	ch, err := worker.Consumer.Subscribe("metrics")
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, server.Producer.PublishSync(ctx, "metrics", []byte(`{"value":1}`), nil))
+
+	select {
+	case msg := <-ch:
+		assert.Equal(t, `{"value":1}`, string(msg.Data()))
+		require.NoError(t, msg.Ack())
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestRegistries_SharedMemoryBroker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte("BROKER: memory\nSINK: log\nDLQ_SUBJECT: dlq.metrics\n"), 0o644))
+
+	config, err := initializers.NewConfig(path)
+	require.NoError(t, err)
+	serverSettings, err := initializers.LoadServerConfig(config)
+	require.NoError(t, err)
+	workerSettings, err := initializers.LoadWorkerConfig(config)
+	require.NoError(t, err)
+
+	server, err := registries.NewServerAppRegistry(config, serverSettings)
+	require.NoError(t, err)
+	worker, err := registries.NewWorkerAppRegistry(config, workerSettings)
+	require.NoError(t, err)
+
+	ch, err := worker.Consumer.Subscribe("metrics")
+	require.NoError(t, err)
+
+	// The broker outlives the server's producer while the worker uses it.
+	require.NoError(t, server.Producer.Close())
+	require.NoError(t, server.Producer.Close())
+	require.NoError(t, worker.DeadLetter.Publish("metrics", []byte(`{"value":1}`), nil))
+	select {
+	case msg := <-ch:
+		assert.Equal(t, `{"value":1}`, string(msg.Data()))
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	// The last holder closes it.
+	require.NoError(t, worker.DeadLetter.Close())
+	require.NoError(t, worker.Consumer.Close())
+	_, ok := <-ch
+	assert.False(t, ok)
+}
@@ -0,0 +1,17 @@
+package registries
+
+// cleanups undoes the steps of a registry constructor that fails part way, so
+// that what was already started does not outlive the failed registry.
+type cleanups []func()
+
+// add registers a function undoing the last step.
+func (c *cleanups) add(undo func()) {
+	*c = append(*c, undo)
+}
+
+// run undoes the steps in reverse order.
+func (c cleanups) run() {
+	for i := len(c) - 1; i >= 0; i-- {
+		c[i]()
+	}
+}
@@ -0,0 +1,274 @@
+package registries
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"golang.org/x/exp/slog"
+	"play.ground/generic-data-collector/initializers"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+// Reloader is implemented by the registries, which apply config changes
+// while running.
+type Reloader interface {
+	// ReloadConfig reads the config file again and applies the changes. It
+	// logs the outcome itself.
+	ReloadConfig() error
+}
+
+// configMu serializes the reads of config files. Viper is not safe for
+// concurrent use, and a config may be read again by the file watcher, on
+// SIGHUP, and by each of the registries sharing it.
+var configMu sync.Mutex
+
+// WatchConfig reloads the config of every registry each time the config file
+// changes. Registries sharing a config should be watched by a single call, so
+// that they reload one after the other.
+//
+// The file is watched here rather than by viper, which would read it again
+// outside of configMu.
+func WatchConfig(config *viper.Viper, registries ...Reloader) error {
+	path := config.ConfigFileUsed()
+	if path == "" {
+		return errors.New("no config file to watch")
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// The directory is watched to pick up files replaced by atomic saves.
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
+	}
+	go watchConfigFile(watcher, filepath.Clean(path), registries)
+	return nil
+}
+
+// watchConfigFile reloads the registries each time the config file is written
+// or created, or the file its path links to changes, as when Kubernetes
+// updates a ConfigMap.
+func watchConfigFile(watcher *fsnotify.Watcher, path string, registries []Reloader) {
+	defer watcher.Close()
+
+	target, _ := filepath.EvalSymlinks(path)
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			current, _ := filepath.EvalSymlinks(path)
+			written := filepath.Clean(event.Name) == path && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create))
+			if !written && (current == "" || current == target) {
+				continue
+			}
+			target = current
+			for _, registry := range registries {
+				_ = registry.ReloadConfig()
+			}
+		case _, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// WatchConfig reloads the config every time its file changes.
+func (r *ServerAppRegistry) WatchConfig() error {
+	return WatchConfig(r.Config, r)
+}
+
+// ReloadConfig reads the config file again and applies the changes that can be
+// applied while running: log levels, topics, rate limits and the schema
+// directory. The JSON schemas are reloaded as well, even if the config did not
+// change. Other changes are logged as requiring a restart. An invalid config
+// is rejected as a whole and the current settings are kept.
+func (r *ServerAppRegistry) ReloadConfig() error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	next, err := reloadSettings(r.Config, initializers.LoadServerConfig)
+	if err != nil {
+		return reloadFailed(r.Logger, r.Metrics, err)
+	}
+	current := r.Settings
+	if r.applied != nil {
+		current = *r.applied
+	}
+
+	// effective collects the settings in effect as they are applied, so that
+	// a change that fails to apply is still pending.
+	effective := current
+	var errs []error
+	changed := initializers.ChangedSettings(current, next)
+	if changedAny(changed, "LOG_LEVEL", "LOG_LEVELS") {
+		if err := r.Logging.SetLevels(next.Log.Level, next.Log.Levels); err != nil {
+			errs = append(errs, fmt.Errorf("LOG_LEVELS: %w", err))
+		} else {
+			effective.Log.Level, effective.Log.Levels = next.Log.Level, next.Log.Levels
+		}
+	}
+	if changedAny(changed, "TOPICS") {
+		if err := r.Topics.SetRoutes(next.Topics); err != nil {
+			errs = append(errs, fmt.Errorf("TOPICS: %w", err))
+		} else {
+			effective.Topics = next.Topics
+		}
+	}
+	// Rate limiting can be retuned, but only a restart turns it on or off.
+	if changedAny(changed, "RATE_LIMIT", "RATE_LIMIT_CLIENTS") && r.RateLimiter != nil {
+		if defaults, ok := rateLimitDefaults(next); ok {
+			r.RateLimiter.SetLimits(defaults, next.RateLimitClients)
+			effective.RateLimit, effective.RateLimitClients = next.RateLimit, next.RateLimitClients
+		}
+	}
+	if changedAny(changed, "RATE_LIMIT_IP") && r.IPRateLimiter != nil && next.RateLimitIP != nil {
+		r.IPRateLimiter.SetLimits(*next.RateLimitIP, nil)
+		effective.RateLimitIP = next.RateLimitIP
+	}
+	// Likewise, schema validation can only be turned on or off by a restart.
+	if r.Schemas != nil && next.SchemaDir != "" {
+		if err := r.Schemas.ReloadFrom(next.SchemaDir); err != nil {
+			errs = append(errs, fmt.Errorf("SCHEMA_DIR: %w", err))
+		} else {
+			effective.SchemaDir = next.SchemaDir
+			r.Logger.Info("Reloaded JSON schemas", "dir", next.SchemaDir, "topics", strings.Join(r.Schemas.Topics(), ", "))
+		}
+	}
+
+	r.applied = &effective
+	err = errors.Join(errs...)
+	reloaded(r.Logger, r.Metrics, initializers.ChangedSettings(current, effective), initializers.ChangedSettings(effective, next), err)
+	return err
+}
+
+// WatchConfig reloads the config every time its file changes.
+func (r *WorkerAppRegistry) WatchConfig() error {
+	return WatchConfig(r.Config, r)
+}
+
+// ReloadConfig reads the config file again and applies the changes that can be
+// applied while running: log levels, batch thresholds and, once the batch
+// processor has subscribed, the consumed topics. Other changes are logged as
+// requiring a restart. An invalid config is rejected as a whole and the current
+// settings are kept.
+func (r *WorkerAppRegistry) ReloadConfig() error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	next, err := reloadSettings(r.Config, initializers.LoadWorkerConfig)
+	if err != nil {
+		return reloadFailed(r.Logger, r.Metrics, err)
+	}
+	current := r.Settings
+	if r.applied != nil {
+		current = *r.applied
+	}
+
+	effective := current
+	var errs []error
+	changed := initializers.ChangedSettings(current, next)
+	if changedAny(changed, "LOG_LEVEL", "LOG_LEVELS") {
+		if err := r.Logging.SetLevels(next.Log.Level, next.Log.Levels); err != nil {
+			errs = append(errs, fmt.Errorf("LOG_LEVELS: %w", err))
+		} else {
+			effective.Log.Level, effective.Log.Levels = next.Log.Level, next.Log.Levels
+		}
+	}
+	if changedAny(changed, "BATCH_MAX_RECORDS", "BATCH_MAX_BYTES", "BATCH_MAX_AGE") {
+		if err := r.BatchProcessor.SetBatchLimits(batchLimits(next.Batch)); err != nil {
+			errs = append(errs, fmt.Errorf("BATCH_MAX_RECORDS, BATCH_MAX_BYTES, BATCH_MAX_AGE: %w", err))
+		} else {
+			effective.Batch.MaxRecords = next.Batch.MaxRecords
+			effective.Batch.MaxBytes = next.Batch.MaxBytes
+			effective.Batch.MaxAge = next.Batch.MaxAge
+		}
+	}
+	// Without a running batch processor, the topics are only read at startup.
+	if changedAny(changed, "CONSUMER_TOPICS") && subscribed(r.BatchProcessor) {
+		if err := r.BatchProcessor.SetTopics(next.Topics); err != nil {
+			errs = append(errs, fmt.Errorf("CONSUMER_TOPICS: %w", err))
+		} else {
+			effective.Topics = next.Topics
+			r.Topics = next.Topics
+		}
+	}
+
+	r.applied = &effective
+	err = errors.Join(errs...)
+	reloaded(r.Logger, r.Metrics, initializers.ChangedSettings(current, effective), initializers.ChangedSettings(effective, next), err)
+	return err
+}
+
+// subscribed reports whether the batch processor has subscribed to its topics.
+func subscribed(processor *services.BatchProcessor) bool {
+	select {
+	case <-processor.Subscribed():
+		return true
+	default:
+		return false
+	}
+}
+
+// reloadSettings reads the config file again and loads the settings from it.
+func reloadSettings[T any](config *viper.Viper, load func(*viper.Viper) (T, error)) (T, error) {
+	if config == nil {
+		var settings T
+		return settings, errors.New("no config file to reload")
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	if err := config.ReadInConfig(); err != nil {
+		var settings T
+		return settings, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return load(config)
+}
+
+// reloadFailed reports a config that could not be reloaded.
+func reloadFailed(logger *slog.Logger, metrics *services.Metrics, err error) error {
+	logger.Error("Failed to reload config, keeping the current settings", "error", err)
+	if metrics != nil {
+		metrics.ObserveConfigReload(err)
+	}
+	return err
+}
+
+// reloaded reports the settings applied by a reload and those that require a
+// restart.
+func reloaded(logger *slog.Logger, metrics *services.Metrics, applied, pending []string, err error) {
+	if err != nil {
+		logger.Error("Failed to apply some config changes", "error", err)
+	}
+	if len(applied) > 0 {
+		logger.Info("Applied config changes", "settings", strings.Join(applied, ", "))
+	}
+	if len(pending) > 0 {
+		logger.Warn("Changed settings require a restart", "settings", strings.Join(pending, ", "))
+	}
+	if metrics != nil {
+		metrics.ObserveConfigReload(err)
+		metrics.SetRestartPending(len(pending) > 0)
+	}
+}
+
+// changedAny reports whether any of the settings is in changed.
+func changedAny(changed []string, settings ...string) bool {
+	for _, setting := range settings {
+		for _, c := range changed {
+			if c == setting {
+				return true
+			}
+		}
+	}
+	return false
+}
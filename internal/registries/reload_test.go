@@ -0,0 +1,146 @@
+package registries_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+	"play.ground/generic-data-collector/initializers"
+	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+func TestServerAppRegistry_ReloadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeFile := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	writeFile("TOPICS:\n  metrics: \"\"\n")
+
+	config, err := initializers.NewConfig(path)
+	require.NoError(t, err)
+	settings, err := initializers.LoadServerConfig(config)
+	require.NoError(t, err)
+
+	registry := registries.NewMockServerAppRegistry()
+	registry.Config = config
+	registry.Settings = settings
+	registry.Metrics = services.NewMetrics()
+	require.NoError(t, registry.Topics.SetRoutes(settings.Topics))
+
+	// Topics and log levels apply at once; the listen address only after a restart.
+	writeFile("TOPICS:\n  events: ingest.events\nLOG_LEVEL: error\nHTTP_ADDR: \":9090\"\n")
+	require.NoError(t, registry.ReloadConfig())
+	assert.Equal(t, []string{"events"}, registry.Topics.Topics())
+	assert.False(t, registry.Logger.Enabled(context.Background(), slog.LevelWarn))
+	assert.Equal(t, initializers.DefaultHTTPAddr, registry.Settings.HTTPAddr)
+
+	// An invalid config is rejected as a whole.
+	writeFile("TOPICS:\n  logs: \"\"\nLOG_LEVEL: loud\n")
+	require.Error(t, registry.ReloadConfig())
+	assert.Equal(t, []string{"events"}, registry.Topics.Topics())
+
+	w := httptest.NewRecorder()
+	registry.Metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `ingest_config_reloads_total{result="ok"} 1`)
+	assert.Contains(t, w.Body.String(), `ingest_config_reloads_total{result="error"} 1`)
+	assert.Contains(t, w.Body.String(), "ingest_config_restart_pending 1")
+}
+
+func TestWorkerAppRegistry_ReloadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte("BATCH_MAX_RECORDS: 100\n"), 0o644))
+
+	config, err := initializers.NewConfig(path)
+	require.NoError(t, err)
+	settings, err := initializers.LoadWorkerConfig(config)
+	require.NoError(t, err)
+
+	registry := registries.NewMockWorkerAppRegistry()
+	registry.Config = config
+	registry.Settings = settings
+	require.NoError(t, registry.BatchProcessor.SetBatchLimits(services.BatchLimits{MaxRecords: settings.Batch.MaxRecords}))
+
+	require.NoError(t, os.WriteFile(path, []byte("BATCH_MAX_RECORDS: 10\nBATCH_MAX_BYTES: 0\n"), 0o644))
+	require.NoError(t, registry.ReloadConfig())
+	assert.Equal(t, 10, registry.BatchProcessor.BatchLimits().MaxRecords)
+	assert.Zero(t, registry.BatchProcessor.BatchLimits().MaxBytes)
+
+	// Limits that would never flush are rejected.
+	require.NoError(t, os.WriteFile(path, []byte("BATCH_MAX_RECORDS: 0\nBATCH_MAX_BYTES: 0\nBATCH_MAX_AGE: 0s\n"), 0o644))
+	require.Error(t, registry.ReloadConfig())
+	assert.Equal(t, 10, registry.BatchProcessor.BatchLimits().MaxRecords)
+	assert.Error(t, registry.BatchProcessor.SetBatchLimits(services.BatchLimits{}))
+}
+
+func TestWorkerAppRegistry_ReloadConfigTopics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte("CONSUMER_TOPICS: [\"metrics\"]\n"), 0o644))
+
+	config, err := initializers.NewConfig(path)
+	require.NoError(t, err)
+	settings, err := initializers.LoadWorkerConfig(config)
+	require.NoError(t, err)
+
+	broker, err := services.NewMemoryBroker(services.MemoryBrokerConfig{})
+	require.NoError(t, err)
+	registry := registries.NewMockWorkerAppRegistry()
+	registry.Config = config
+	registry.Settings = settings
+	registry.Topics = settings.Topics
+	registry.BatchProcessor = services.NewBatchProcessor(broker, registry.Sink, services.WithLogger(registry.Logger))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = registry.BatchProcessor.Start(ctx, registry.Topics...) }()
+	<-registry.BatchProcessor.Subscribed()
+
+	require.NoError(t, os.WriteFile(path, []byte("CONSUMER_TOPICS: [\"ingest.>\"]\n"), 0o644))
+	require.NoError(t, registry.ReloadConfig())
+	assert.Equal(t, []string{"ingest.>"}, registry.Topics)
+
+	require.NoError(t, broker.Publish("metrics", []byte(`{}`), nil))
+	require.NoError(t, broker.Publish("ingest.events", []byte(`{}`), nil))
+	require.Eventually(t, func() bool { return registry.BatchProcessor.Stats().Received == 1 }, 2*time.Second, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, uint64(1), registry.BatchProcessor.Stats().Received)
+}
+
+func TestWatchConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte("TOPICS:\n  metrics: \"\"\n"), 0o644))
+
+	config, err := initializers.NewConfig(path)
+	require.NoError(t, err)
+	settings, err := initializers.LoadServerConfig(config)
+	require.NoError(t, err)
+
+	registry := registries.NewMockServerAppRegistry()
+	registry.Config = config
+	registry.Settings = settings
+	require.NoError(t, registry.Topics.SetRoutes(settings.Topics))
+	require.NoError(t, registry.WatchConfig())
+
+	// Reloads triggered by the watcher and by SIGHUP may overlap.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			_ = registry.ReloadConfig()
+		}
+	}()
+	require.NoError(t, os.WriteFile(path, []byte("TOPICS:\n  events: ingest.events\n"), 0o644))
+	<-done
+
+	require.Eventually(t, func() bool {
+		topics := registry.Topics.Topics()
+		return len(topics) == 1 && topics[0] == "events"
+	}, 2*time.Second, 10*time.Millisecond)
+}
@@ -1,45 +1,205 @@
 package registries
 
 import (
-	"log"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/viper"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/exp/slog"
 	"play.ground/generic-data-collector/initializers"
 	"play.ground/generic-data-collector/internal/interfaces"
 	"play.ground/generic-data-collector/internal/services"
 )
 
-const DefaultNATSUrl = "nats://localhost:4222"
-
 type ServerAppRegistry struct {
-	Config   *viper.Viper
+	// Config is the source of Settings.
+	Config *viper.Viper
+	// Settings are the validated settings the registry was created from.
+	Settings initializers.ServerConfig
+
+	// reloadMu serializes config reloads.
+	reloadMu sync.Mutex
+	// applied are the settings in effect once reloadable changes are applied;
+	// nil until the first reload.
+	applied *initializers.ServerConfig
+
 	Producer interfaces.Producer
+	// Logging creates the loggers of each component.
+	Logging *services.Logging
+	// Logger is the logger of the server component; request handlers log
+	// through a copy tagged with the request ID.
+	Logger *slog.Logger
+	// Topics maps the topics accepted by the API to broker subjects.
+	Topics *services.TopicRouter
+	// Schemas validates records per topic; nil when no SCHEMA_DIR is configured.
+	Schemas *services.SchemaRegistry
+	// APIKeys authenticates API clients; nil disables authentication.
+	APIKeys *services.APIKeyStore
+	// RateLimiter limits requests per client; nil disables rate limiting.
+	RateLimiter *services.RateLimiter
+	// IPRateLimiter limits requests per IP address before authentication; nil
+	// disables it.
+	IPRateLimiter *services.RateLimiter
+	// MaxBodyBytes caps the size of request bodies after decompression.
+	MaxBodyBytes int64
+	// Metrics holds the server's Prometheus collectors.
+	Metrics *services.Metrics
+	// TracerProvider exports the server's spans; nil when tracing is disabled.
+	TracerProvider *sdktrace.TracerProvider
+	// EmbeddedNATS is the NATS server running in the process; nil unless
+	// NATS_EMBEDDED is set.
+	EmbeddedNATS *services.EmbeddedNATS
+	// HTTPAddr is the address the HTTP server listens on.
+	HTTPAddr string
+	// ShutdownTimeout bounds how long shutdown waits for in-flight requests
+	// and publishes.
+	ShutdownTimeout time.Duration
+	// TrustedProxies are the proxies whose X-Forwarded-For headers are trusted
+	// to tell the client IP.
+	TrustedProxies []string
+	// SyncPublish makes handlers wait for the broker to confirm each message
+	// before responding, instead of publishing in the background.
+	SyncPublish bool
+	// PublishTimeout bounds how long a synchronous publish may wait for confirmation.
+	PublishTimeout time.Duration
+	// Publishes tracks asynchronous publishes, which shutdown waits for before
+	// closing the producer.
+	Publishes services.InFlight
 }
 
-func NewServerAppRegistry() (*ServerAppRegistry, error) {
-	env := getEnv()
-	config := initializers.NewConfig(env)
-	natsUrl := config.GetString("NATS_URL")
-	if natsUrl == "" {
-		log.Printf("NATS_URL not set in config, using default: %s", DefaultNATSUrl)
-		natsUrl = DefaultNATSUrl
+// NewServerAppRegistry creates the server's dependencies from the validated
+// settings. config is the source the settings were loaded from.
+func NewServerAppRegistry(config *viper.Viper, settings initializers.ServerConfig) (_ *ServerAppRegistry, err error) {
+	var undo cleanups
+	defer func() {
+		if err != nil {
+			undo.run()
+		}
+	}()
+
+	logging, err := services.NewLogging(logConfig(settings.Log), os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	logger := logging.Logger(services.LogComponentServer)
+
+	topics, err := services.NewTopicRouter(settings.Topics)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOPICS: %w", err)
+	}
+
+	var schemas *services.SchemaRegistry
+	if dir := settings.SchemaDir; dir != "" {
+		schemas, err = services.NewSchemaRegistry(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JSON schemas: %w", err)
+		}
+		logger.Info("Loaded JSON schemas", "dir", dir, "topics", strings.Join(schemas.Topics(), ", "))
+	}
+
+	apiKeys, err := getAPIKeys(settings, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	tracerProvider, err := newTracerProvider(settings.Tracing, ServerServiceName)
+	if err != nil {
+		return nil, err
 	}
+	undo.add(func() { shutdownTracerProvider(tracerProvider) })
 
-	natsProducer, err := services.NewNATSProducer(natsUrl)
+	broker, embeddedNATS, err := startEmbeddedNATS(settings.Broker)
+	if err != nil {
+		return nil, err
+	}
+	if embeddedNATS != nil {
+		undo.add(releaseEmbeddedNATS)
+	}
+	producer, err := newProducer(broker)
 	if err != nil {
-		log.Fatalf("Failed to create NATS producer: %v", err)
 		return nil, err
 	}
 
+	metrics := services.NewMetrics()
+	producer = metrics.InstrumentProducer(producer)
+
 	return &ServerAppRegistry{
-		Config:   config,
-		Producer: natsProducer,
+		Config:          config,
+		Settings:        settings,
+		Producer:        producer,
+		Logging:         logging,
+		Logger:          logger,
+		Topics:          topics,
+		Schemas:         schemas,
+		APIKeys:         apiKeys,
+		RateLimiter:     getRateLimiter(settings),
+		IPRateLimiter:   getIPRateLimiter(settings),
+		Metrics:         metrics,
+		TracerProvider:  tracerProvider,
+		EmbeddedNATS:    embeddedNATS,
+		MaxBodyBytes:    settings.MaxBodyBytes,
+		HTTPAddr:        settings.HTTPAddr,
+		ShutdownTimeout: settings.ShutdownTimeout,
+		TrustedProxies:  settings.TrustedProxies,
+		SyncPublish:     settings.PublishMode == initializers.PublishModeSync,
+		PublishTimeout:  settings.PublishTimeout,
 	}, nil
 }
 
+// HealthChecks returns the dependencies that must be healthy for the server to
+// accept records.
+func (r *ServerAppRegistry) HealthChecks() map[string]interfaces.HealthChecker {
+	return map[string]interfaces.HealthChecker{"broker": r.Producer}
+}
+
+// getRateLimiter builds the rate limiter from the RATE_LIMIT defaults and the
+// RATE_LIMIT_CLIENTS map of client IDs to their own limits. Without either
+// setting, rate limiting is disabled and nil is returned.
+func getRateLimiter(settings initializers.ServerConfig) *services.RateLimiter {
+	defaults, ok := rateLimitDefaults(settings)
+	if !ok {
+		return nil
+	}
+	return services.NewRateLimiter(defaults, settings.RateLimitClients)
+}
+
+// getIPRateLimiter builds the limiter of requests per IP address from the
+// RATE_LIMIT_IP setting. Without it, nil is returned.
+func getIPRateLimiter(settings initializers.ServerConfig) *services.RateLimiter {
+	if settings.RateLimitIP == nil {
+		return nil
+	}
+	return services.NewRateLimiter(*settings.RateLimitIP, nil)
+}
+
+// rateLimitDefaults returns the default limits of every client, and whether
+// rate limiting is enabled.
+func rateLimitDefaults(settings initializers.ServerConfig) (services.RateLimit, bool) {
+	if settings.RateLimit == nil && settings.RateLimitClients == nil {
+		return services.RateLimit{}, false
+	}
+	if settings.RateLimit == nil {
+		return services.RateLimit{}, true
+	}
+	return *settings.RateLimit, true
+}
+
 // NewMockServerAppRegistry creates a ServerAppRegistry with a MockProducer for testing.
 func NewMockServerAppRegistry() *ServerAppRegistry {
+	settings := initializers.DefaultServerConfig()
+	topics, _ := services.NewTopicRouter(settings.Topics)
+	logging := services.DiscardLogging()
 	return &ServerAppRegistry{
-		Producer: services.NewMockProducer(),
+		Settings:       settings,
+		Producer:       services.NewMockProducer(),
+		Logging:        logging,
+		Logger:         logging.Logger(services.LogComponentServer),
+		Topics:         topics,
+		PublishTimeout: settings.PublishTimeout,
+		MaxBodyBytes:   settings.MaxBodyBytes,
 	}
 }
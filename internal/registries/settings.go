@@ -0,0 +1,27 @@
+package registries
+
+import (
+	"play.ground/generic-data-collector/initializers"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+// logConfig returns the settings of the loggers.
+func logConfig(settings initializers.LogConfig) services.LogConfig {
+	return services.LogConfig{Level: settings.Level, Format: settings.Format, Components: settings.Levels}
+}
+
+// batchLimits returns the thresholds that trigger a flush.
+func batchLimits(settings initializers.BatchConfig) services.BatchLimits {
+	return services.BatchLimits{MaxRecords: settings.MaxRecords, MaxBytes: settings.MaxBytes, MaxAge: settings.MaxAge}
+}
+
+// retryPolicy returns the policy used to retry failed sink writes.
+func retryPolicy(settings initializers.RetryConfig) services.RetryPolicy {
+	return services.RetryPolicy{
+		MaxAttempts:     settings.MaxAttempts,
+		InitialInterval: settings.InitialInterval,
+		MaxInterval:     settings.MaxInterval,
+		Multiplier:      settings.Multiplier,
+		MaxElapsedTime:  settings.MaxElapsedTime,
+	}
+}
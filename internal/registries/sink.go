@@ -0,0 +1,21 @@
+package registries
+
+import (
+	"golang.org/x/exp/slog"
+	"play.ground/generic-data-collector/initializers"
+	"play.ground/generic-data-collector/internal/interfaces"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+// newSink builds the sink selected by the SINK setting. The log sink writes
+// records to the logger.
+func newSink(settings initializers.SinkConfig, logger *slog.Logger) (interfaces.Sink, error) {
+	switch settings.Type {
+	case initializers.SinkFile:
+		return services.NewFileSink(settings.FilePath)
+	case initializers.SinkHTTP:
+		return services.NewHTTPSink(settings.HTTPURL, settings.HTTPHealthURL, settings.HTTPTimeout)
+	default:
+		return services.NewLogSink(logger), nil
+	}
+}
@@ -0,0 +1,45 @@
+package registries
+
+import (
+	"context"
+	"fmt"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"play.ground/generic-data-collector/initializers"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+const (
+	ServerServiceName = "ingest-server"
+	WorkerServiceName = "ingest-worker"
+)
+
+// newTracerProvider sets up tracing from the TRACING_* settings. It returns nil
+// when tracing is disabled.
+func newTracerProvider(settings initializers.TracingConfig, serviceName string) (*sdktrace.TracerProvider, error) {
+	provider, err := services.NewTracerProvider(context.Background(), tracingConfig(settings, serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	return provider, nil
+}
+
+// shutdownTracerProvider shuts down the provider of a registry that failed to
+// initialize, if tracing is enabled.
+func shutdownTracerProvider(provider *sdktrace.TracerProvider) {
+	if provider != nil {
+		provider.Shutdown(context.Background())
+	}
+}
+
+// tracingConfig returns the tracing settings of the named service.
+func tracingConfig(settings initializers.TracingConfig, serviceName string) services.TracingConfig {
+	return services.TracingConfig{
+		ServiceName: serviceName,
+		Exporter:    settings.Exporter,
+		Endpoint:    settings.Endpoint,
+		Insecure:    settings.Insecure,
+		FilePath:    settings.FilePath,
+		SampleRatio: *settings.SampleRatio,
+	}
+}
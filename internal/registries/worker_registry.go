@@ -1,50 +1,165 @@
 package registries
 
 import (
-	"log"
+	"fmt"
+	"os"
+	"sync"
 
 	"github.com/spf13/viper"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/exp/slog"
 	"play.ground/generic-data-collector/initializers"
 	"play.ground/generic-data-collector/internal/interfaces"
 	"play.ground/generic-data-collector/internal/services"
 )
 
 type WorkerAppRegistry struct {
-	Config         *viper.Viper
-	Consumer       interfaces.Consumer
+	// Config is the source of Settings.
+	Config *viper.Viper
+	// Settings are the validated settings the registry was created from.
+	Settings initializers.WorkerConfig
+
+	// reloadMu serializes config reloads.
+	reloadMu sync.Mutex
+	// applied are the settings in effect once reloadable changes are applied;
+	// nil until the first reload.
+	applied *initializers.WorkerConfig
+
+	Consumer interfaces.Consumer
+	// Logging creates the loggers of each component.
+	Logging *services.Logging
+	// Logger is the logger of the worker component.
+	Logger *slog.Logger
+	// Topics are the subjects the worker subscribes to; they may contain wildcards.
+	Topics []string
+	// QueueGroup is the queue group shared by all workers; empty means every
+	// worker receives every message.
+	QueueGroup string
+	Sink       interfaces.Sink
+	// DeadLetter publishes records that exhausted their retries; nil when no
+	// DLQ_SUBJECT is configured.
+	DeadLetter     interfaces.Producer
 	BatchProcessor *services.BatchProcessor
+	// Metrics holds the worker's Prometheus collectors.
+	Metrics *services.Metrics
+	// TracerProvider exports the worker's spans; nil when tracing is disabled.
+	TracerProvider *sdktrace.TracerProvider
+	// EmbeddedNATS is the NATS server running in the process; nil unless
+	// NATS_EMBEDDED is set.
+	EmbeddedNATS *services.EmbeddedNATS
+	// AdminAddr is the address of the admin HTTP listener serving the health endpoints.
+	AdminAddr string
 }
 
-func NewWorkerAppRegistry() (*WorkerAppRegistry, error) {
-	env := getEnv()
-	config := initializers.NewConfig(env)
+// NewWorkerAppRegistry creates the worker's dependencies from the validated
+// settings. config is the source the settings were loaded from.
+func NewWorkerAppRegistry(config *viper.Viper, settings initializers.WorkerConfig) (_ *WorkerAppRegistry, err error) {
+	var undo cleanups
+	defer func() {
+		if err != nil {
+			undo.run()
+		}
+	}()
+
+	logging, err := services.NewLogging(logConfig(settings.Log), os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	logger := logging.Logger(services.LogComponentWorker)
 
-	natsUrl := config.GetString("NATS_URL")
-	if natsUrl == "" {
-		log.Printf("NATS_URL not set in config, using default: %s", DefaultNATSUrl)
-		natsUrl = DefaultNATSUrl
+	tracerProvider, err := newTracerProvider(settings.Tracing, WorkerServiceName)
+	if err != nil {
+		return nil, err
 	}
+	undo.add(func() { shutdownTracerProvider(tracerProvider) })
 
-	natsConsumer, err := services.NewNATSConsumer(natsUrl)
+	broker, embeddedNATS, err := startEmbeddedNATS(settings.Broker)
+	if err != nil {
+		return nil, err
+	}
+	if embeddedNATS != nil {
+		undo.add(releaseEmbeddedNATS)
+	}
+	consumer, err := newConsumer(broker)
 	if err != nil {
-		log.Fatalf("Failed to create NATS consumer: %v", err)
 		return nil, err
 	}
+	undo.add(func() { consumer.Close() })
 
-	batchProcessor := services.NewBatchProcessor(natsConsumer)
+	sink, err := newSink(settings.Sink, logging.Logger(services.LogComponentSink))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sink: %w", err)
+	}
+	undo.add(func() { sink.Close() })
+
+	metrics := services.NewMetrics()
+	metrics.RegisterConsumer(consumer)
+
+	opts := []services.BatchProcessorOption{
+		services.WithMetrics(metrics),
+		services.WithLogger(logging.Logger(services.LogComponentBatch)),
+		services.WithBatchLimits(batchLimits(settings.Batch)),
+		services.WithRetryPolicy(retryPolicy(settings.Retry)),
+		services.WithFlushConcurrency(settings.Batch.FlushConcurrency, settings.Batch.FlushMaxInFlight),
+		services.WithQueueGroup(settings.QueueGroup),
+	}
+
+	var deadLetter interfaces.Producer
+	if settings.DeadLetter.Subject != "" {
+		deadLetter, err = newDeadLetterProducer(broker, settings.DeadLetter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dead-letter producer: %w", err)
+		}
+		deadLetter = metrics.InstrumentProducer(deadLetter)
+		opts = append(opts, services.WithDeadLetter(deadLetter, settings.DeadLetter.Subject))
+	}
+
+	batchProcessor := services.NewBatchProcessor(consumer, sink, opts...)
 
 	return &WorkerAppRegistry{
 		Config:         config,
-		Consumer:       natsConsumer,
+		Settings:       settings,
+		Consumer:       consumer,
+		Logging:        logging,
+		Logger:         logger,
+		Topics:         settings.Topics,
+		QueueGroup:     settings.QueueGroup,
+		Sink:           sink,
+		DeadLetter:     deadLetter,
 		BatchProcessor: batchProcessor,
+		Metrics:        metrics,
+		TracerProvider: tracerProvider,
+		EmbeddedNATS:   embeddedNATS,
+		AdminAddr:      settings.AdminAddr,
 	}, nil
 }
 
-// NewMockWorkerAppRegistry creates a WorkerAppRegistry with a MockConsumer for testing.
+// HealthChecks returns the dependencies that must be healthy for the worker to
+// process records.
+func (r *WorkerAppRegistry) HealthChecks() map[string]interfaces.HealthChecker {
+	checks := map[string]interfaces.HealthChecker{
+		"broker": r.Consumer,
+		"sink":   r.Sink,
+	}
+	if r.DeadLetter != nil {
+		checks["dead_letter"] = r.DeadLetter
+	}
+	return checks
+}
+
+// NewMockWorkerAppRegistry creates a WorkerAppRegistry with a MockConsumer and a MockSink for testing.
 func NewMockWorkerAppRegistry() *WorkerAppRegistry {
 	mockConsumer := services.NewMockConsumer()
+	mockSink := services.NewMockSink()
+	settings := initializers.DefaultWorkerConfig()
+	logging := services.DiscardLogging()
 	return &WorkerAppRegistry{
+		Settings:       settings,
 		Consumer:       mockConsumer,
-		BatchProcessor: services.NewBatchProcessor(mockConsumer),
+		Logging:        logging,
+		Logger:         logging.Logger(services.LogComponentWorker),
+		Topics:         settings.Topics,
+		Sink:           mockSink,
+		BatchProcessor: services.NewBatchProcessor(mockConsumer, mockSink, services.WithLogger(logging.Logger(services.LogComponentBatch))),
 	}
 }
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"net/http"
+
+	"play.ground/generic-data-collector/internal/handlers"
+	"play.ground/generic-data-collector/internal/registries"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewWorkerAdmin creates the worker's admin HTTP server, which serves the
+// health and metrics endpoints on the registry's AdminAddr. The caller starts and shuts it down.
+func NewWorkerAdmin(registry *registries.WorkerAppRegistry) *http.Server {
+	router := gin.New()
+	router.Use(gin.Recovery())
+
+	router.GET("/healthz", handlers.Liveness)
+	router.GET("/readyz", handlers.Readiness(registry.HealthChecks()))
+	if registry.Metrics != nil {
+		router.GET("/metrics", gin.WrapH(registry.Metrics.Handler()))
+	}
+
+	return &http.Server{
+		Addr:    registry.AdminAddr,
+		Handler: router,
+	}
+}
@@ -1,17 +1,37 @@
 package routes
 
 import (
-	"log"
+	"fmt"
+	"net/http"
 
 	"play.ground/generic-data-collector/internal/handlers"
 	"play.ground/generic-data-collector/internal/registries"
+	"play.ground/generic-data-collector/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Run starts the HTTP server.
-func Run(registry *registries.ServerAppRegistry) {
-	router := gin.Default()
+// NewServer creates the HTTP server listening on the registry's HTTPAddr. The
+// caller starts it and shuts it down.
+func NewServer(registry *registries.ServerAppRegistry) (*http.Server, error) {
+	router, err := NewRouter(registry)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Server{
+		Addr:    registry.HTTPAddr,
+		Handler: router,
+	}, nil
+}
+
+// NewRouter creates the router serving the API. Requests are logged by the
+// registry's "http" logger.
+func NewRouter(registry *registries.ServerAppRegistry) (*gin.Engine, error) {
+	router := gin.New()
+	router.Use(gin.Recovery())
+	if err := router.SetTrustedProxies(registry.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
 
 	// Helper function to pass registry to handlers
 	withRegistry := func(handler func(*gin.Context, *registries.ServerAppRegistry)) gin.HandlerFunc {
@@ -20,14 +40,35 @@ func Run(registry *registries.ServerAppRegistry) {
 		}
 	}
 
+	router.Use(
+		withRegistry(handlers.RequestID),
+		handlers.LogRequests(registry.Logging.Logger(services.LogComponentHTTP)),
+		withRegistry(handlers.Instrument),
+		handlers.Trace,
+	)
+
+	// Health and metrics endpoints are not authenticated, so that orchestrators
+	// and Prometheus can probe them.
+	router.GET("/healthz", handlers.Liveness)
+	router.GET("/readyz", handlers.Readiness(registry.HealthChecks()))
+	if registry.Metrics != nil {
+		router.GET("/metrics", gin.WrapH(registry.Metrics.Handler()))
+	}
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
+	v1.Use(
+		withRegistry(handlers.RateLimitIP),
+		withRegistry(handlers.Authenticate),
+		withRegistry(handlers.Decompress),
+		withRegistry(handlers.RateLimit),
+	)
 	{
 		v1.POST("/metrics", withRegistry(handlers.PostMetric))
+		v1.POST("/metrics/batch", withRegistry(handlers.PostMetricsBatch))
+		v1.POST("/ingest/:topic", withRegistry(handlers.PostIngest))
+		v1.POST("/ingest/:topic/batch", withRegistry(handlers.PostIngestBatch))
 	}
 
-	log.Println("Starting HTTP server on :8080")
-	if err := router.Run(":8080"); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
-	}
+	return router, nil
 }
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// AllTopics grants an API key write access to every topic.
+const AllTopics = "*"
+
+// APIKey is a configured API key. Only the SHA-256 hash of the key is stored,
+// so the configuration does not reveal the keys themselves.
+type APIKey struct {
+	// Client is the identity of the key's owner, attached to published messages.
+	Client string `mapstructure:"client"`
+	// SHA256 is the hex-encoded SHA-256 hash of the key.
+	SHA256 string `mapstructure:"sha256" redact:"true"`
+	// Topics are the API topics the key may write to; "*" allows all topics.
+	Topics []string `mapstructure:"topics"`
+}
+
+// Client is the identity of an authenticated API client.
+type Client struct {
+	ID     string
+	topics map[string]bool
+}
+
+// CanWrite reports whether the client may publish to the API topic.
+func (c *Client) CanWrite(topic string) bool {
+	return c.topics[AllTopics] || c.topics[topic]
+}
+
+// APIKeyStore authenticates API keys against their configured hashes.
+type APIKeyStore struct {
+	clients map[[sha256.Size]byte]*Client
+}
+
+// NewAPIKeyStore validates the keys and builds a store from them.
+func NewAPIKeyStore(keys []APIKey) (*APIKeyStore, error) {
+	store := &APIKeyStore{clients: make(map[[sha256.Size]byte]*Client, len(keys))}
+	for i, key := range keys {
+		if key.Client == "" {
+			return nil, fmt.Errorf("api key %d: client is required", i)
+		}
+		hash, err := parseKeyHash(key.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("api key for client %q: %w", key.Client, err)
+		}
+		if _, ok := store.clients[hash]; ok {
+			return nil, fmt.Errorf("api key for client %q: duplicate key", key.Client)
+		}
+		if len(key.Topics) == 0 {
+			return nil, fmt.Errorf("api key for client %q: at least one topic is required", key.Client)
+		}
+
+		client := &Client{ID: key.Client, topics: make(map[string]bool, len(key.Topics))}
+		for _, topic := range key.Topics {
+			client.topics[topic] = true
+		}
+		store.clients[hash] = client
+	}
+	return store, nil
+}
+
+func parseKeyHash(s string) ([sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(b) != sha256.Size {
+		return hash, fmt.Errorf("sha256 must be %d hex characters", 2*sha256.Size)
+	}
+	copy(hash[:], b)
+	return hash, nil
+}
+
+// Authenticate returns the client owning the key. Keys are looked up by their
+// hash, so the lookup time does not depend on how much of a guessed key is right.
+func (s *APIKeyStore) Authenticate(key string) (*Client, bool) {
+	client, ok := s.clients[sha256.Sum256([]byte(key))]
+	return client, ok
+}
+
+// HashAPIKey returns the hex-encoded SHA-256 hash of a key, as expected in APIKey.SHA256.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+type clientContextKey struct{}
+
+// ContextWithClient returns a copy of ctx carrying the authenticated client.
+func ContextWithClient(ctx context.Context, client *Client) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// ClientFromContext returns the authenticated client stored in ctx, if any.
+func ClientFromContext(ctx context.Context) (*Client, bool) {
+	client, ok := ctx.Value(clientContextKey{}).(*Client)
+	return client, ok
+}
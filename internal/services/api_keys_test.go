@@ -0,0 +1,59 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+func TestAPIKeyStore_Authenticate(t *testing.T) {
+	store, err := services.NewAPIKeyStore([]services.APIKey{
+		{Client: "dashboard", SHA256: services.HashAPIKey("secret-1"), Topics: []string{"metrics"}},
+		{Client: "admin", SHA256: services.HashAPIKey("secret-2"), Topics: []string{services.AllTopics}},
+	})
+	require.NoError(t, err)
+
+	client, ok := store.Authenticate("secret-1")
+	require.True(t, ok)
+	assert.Equal(t, "dashboard", client.ID)
+	assert.True(t, client.CanWrite("metrics"))
+	assert.False(t, client.CanWrite("events"))
+
+	client, ok = store.Authenticate("secret-2")
+	require.True(t, ok)
+	assert.True(t, client.CanWrite("events"))
+
+	_, ok = store.Authenticate("secret-3")
+	assert.False(t, ok)
+
+	ctx := services.ContextWithClient(context.Background(), client)
+	fromCtx, ok := services.ClientFromContext(ctx)
+	require.True(t, ok)
+	assert.Same(t, client, fromCtx)
+}
+
+func TestNewAPIKeyStore_InvalidKeys(t *testing.T) {
+	hash := services.HashAPIKey("secret")
+	tests := []struct {
+		name string
+		keys []services.APIKey
+	}{
+		{"missing client", []services.APIKey{{SHA256: hash, Topics: []string{"metrics"}}}},
+		{"malformed hash", []services.APIKey{{Client: "a", SHA256: "not-hex", Topics: []string{"metrics"}}}},
+		{"short hash", []services.APIKey{{Client: "a", SHA256: hash[:32], Topics: []string{"metrics"}}}},
+		{"no topics", []services.APIKey{{Client: "a", SHA256: hash}}},
+		{"duplicate key", []services.APIKey{
+			{Client: "a", SHA256: hash, Topics: []string{"metrics"}},
+			{Client: "b", SHA256: hash, Topics: []string{"metrics"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := services.NewAPIKeyStore(tt.keys)
+			assert.Error(t, err)
+		})
+	}
+}
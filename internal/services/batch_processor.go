@@ -2,112 +2,501 @@ package services
 
 import (
 	"context"
-	"log"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
 	"play.ground/generic-data-collector/internal/interfaces"
 )
 
 const (
-	batchSize    = 10
-	batchTimeout = 5 * time.Second
-	maxRetries   = 3
+	// nakDelay is how long the broker waits before redelivering a failed batch.
+	nakDelay = 5 * time.Second
 )
 
-// BatchProcessor consumes messages, batches them, and sends them to an bulk ingester.
+// BatchLimits are the thresholds that trigger a flush. Whichever limit is hit
+// first flushes the batch; a zero limit is disabled.
+type BatchLimits struct {
+	// MaxRecords is the maximum number of records in a batch.
+	MaxRecords int
+	// MaxBytes is the maximum total payload size of a batch. A single record
+	// larger than MaxBytes is flushed as a batch of its own.
+	MaxBytes int
+	// MaxAge is the maximum time the oldest record may wait in a batch.
+	MaxAge time.Duration
+}
+
+// Validate reports limits that would never flush a batch.
+func (l BatchLimits) Validate() error {
+	if l.MaxRecords <= 0 && l.MaxBytes <= 0 && l.MaxAge <= 0 {
+		return errors.New("at least one batch limit must be positive")
+	}
+	return nil
+}
+
+// DefaultBatchLimits returns the limits used when none are configured.
+func DefaultBatchLimits() BatchLimits {
+	return BatchLimits{
+		MaxRecords: 500,
+		MaxBytes:   1 << 20,
+		MaxAge:     5 * time.Second,
+	}
+}
+
+// FlushReason tells why a batch was flushed.
+type FlushReason string
+
+const (
+	FlushMaxRecords FlushReason = "max_records"
+	FlushMaxBytes   FlushReason = "max_bytes"
+	FlushMaxAge     FlushReason = "max_age"
+	FlushShutdown   FlushReason = "shutdown"
+	FlushDrained    FlushReason = "drained"
+)
+
+// BatchProcessor consumes messages, batches them, and writes them to a sink.
+// Full batches are handed off to a pool of flush workers, so consumption goes
+// on while earlier batches are being written. Batches may therefore reach the
+// sink out of order.
 type BatchProcessor struct {
-	consumer interfaces.Consumer
+	consumer    interfaces.Consumer
+	sink        interfaces.Sink
+	limits      atomic.Pointer[BatchLimits]
+	retry       RetryPolicy
+	stats       *BatchStats
+	concurrency int
+	maxInFlight int
+	queueGroup  string
+
+	deadLetter        interfaces.Producer
+	deadLetterSubject string
+
+	metrics *Metrics
+	logger  *slog.Logger
+
+	// subscribed is closed once Start has subscribed to its topics.
+	subscribed    chan struct{}
+	subscriptions atomic.Pointer[Subscriptions]
+}
+
+// BatchProcessorOption configures optional BatchProcessor behaviour.
+type BatchProcessorOption func(*BatchProcessor)
+
+// WithBatchLimits sets the thresholds that trigger a flush.
+func WithBatchLimits(limits BatchLimits) BatchProcessorOption {
+	return func(p *BatchProcessor) {
+		p.limits.Store(&limits)
+	}
+}
+
+// WithFlushConcurrency sets the number of flush workers writing batches in
+// parallel and the maximum number of batches being written or waiting for a
+// worker. When that many batches are in flight, consumption pauses until one
+// completes.
+func WithFlushConcurrency(workers, maxInFlight int) BatchProcessorOption {
+	return func(p *BatchProcessor) {
+		p.concurrency = workers
+		p.maxInFlight = maxInFlight
+	}
+}
+
+// WithQueueGroup makes the processor subscribe as a member of the queue group,
+// so that several workers share the messages of a topic.
+func WithQueueGroup(group string) BatchProcessorOption {
+	return func(p *BatchProcessor) {
+		p.queueGroup = group
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry failed sink writes.
+func WithRetryPolicy(policy RetryPolicy) BatchProcessorOption {
+	return func(p *BatchProcessor) {
+		p.retry = policy
+	}
 }
 
-// NewBatchProcessor creates a new processor.
-func NewBatchProcessor(consumer interfaces.Consumer) *BatchProcessor {
-	return &BatchProcessor{
+// WithDeadLetter publishes the records of batches that exhaust their retries
+// to the given subject instead of asking the broker to redeliver them.
+func WithDeadLetter(producer interfaces.Producer, subject string) BatchProcessorOption {
+	return func(p *BatchProcessor) {
+		p.deadLetter = producer
+		p.deadLetterSubject = subject
+	}
+}
+
+// WithMetrics exposes the processor's counters and records batch sizes and
+// flush latencies in the given metrics.
+func WithMetrics(metrics *Metrics) BatchProcessorOption {
+	return func(p *BatchProcessor) {
+		p.metrics = metrics
+	}
+}
+
+// WithLogger sets the logger of the processor, which defaults to slog.Default().
+func WithLogger(logger *slog.Logger) BatchProcessorOption {
+	return func(p *BatchProcessor) {
+		p.logger = logger
+	}
+}
+
+// NewBatchProcessor creates a new processor writing batches to the given sink.
+func NewBatchProcessor(consumer interfaces.Consumer, sink interfaces.Sink, opts ...BatchProcessorOption) *BatchProcessor {
+	p := &BatchProcessor{
 		consumer: consumer,
+		sink:     sink,
+		retry:    DefaultRetryPolicy(),
+		stats:    newBatchStats(),
+		logger:   slog.Default(),
+
+		subscribed: make(chan struct{}),
+
+		concurrency: 1,
+		maxInFlight: 2,
+	}
+	limits := DefaultBatchLimits()
+	p.limits.Store(&limits)
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.concurrency < 1 {
+		p.concurrency = 1
+	}
+	if p.maxInFlight < p.concurrency {
+		p.maxInFlight = p.concurrency
+	}
+	if p.metrics != nil {
+		p.metrics.registerBatchStats(p.stats)
+	}
+	return p
+}
+
+// SetBatchLimits changes the thresholds that trigger a flush while the
+// processor runs. The batch being accumulated is checked against the new
+// limits when the next message arrives, and a new MaxAge applies from the next
+// batch on. Limits that would never flush a batch are rejected.
+func (p *BatchProcessor) SetBatchLimits(limits BatchLimits) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+	p.limits.Store(&limits)
+	return nil
+}
+
+// BatchLimits returns the thresholds in effect.
+func (p *BatchProcessor) BatchLimits() BatchLimits {
+	return *p.limits.Load()
+}
+
+// Subscribed returns a channel that is closed once Start has subscribed to its
+// topics, so that messages published from then on are received.
+func (p *BatchProcessor) Subscribed() <-chan struct{} {
+	return p.subscribed
+}
+
+// SetTopics changes the topics, which may contain wildcards, while the
+// processor runs: it subscribes to the new topics and unsubscribes from the
+// dropped ones. Messages already received on the dropped topics are still
+// written.
+func (p *BatchProcessor) SetTopics(topics []string) error {
+	subs := p.subscriptions.Load()
+	if subs == nil {
+		return errors.New("batch processor is not running")
+	}
+	return subs.SetTopics(topics)
+}
+
+// Drain unsubscribes from every topic, so that Start writes the messages
+// already received and returns.
+func (p *BatchProcessor) Drain() error {
+	subs := p.subscriptions.Load()
+	if subs == nil {
+		return errors.New("batch processor is not running")
 	}
+	return subs.Unsubscribe()
+}
+
+// Stats returns a snapshot of the processor's counters.
+func (p *BatchProcessor) Stats() BatchStatsSnapshot {
+	return p.stats.snapshot()
+}
+
+// pendingBatch accumulates messages until one of the limits is hit.
+type pendingBatch struct {
+	records  [][]byte
+	messages []interfaces.Message
+	bytes    int
+}
+
+func (b *pendingBatch) add(msg interfaces.Message) {
+	data := msg.Data()
+	b.records = append(b.records, data)
+	b.messages = append(b.messages, msg)
+	b.bytes += len(data)
 }
 
-// Start runs the main consumer loop. It blocks until the context is canceled.
-func (p *BatchProcessor) Start(ctx context.Context, topic string) error {
-	msgCh, err := p.consumer.Subscribe(topic)
+func (b *pendingBatch) len() int {
+	return len(b.records)
+}
+
+// flushJob is a batch handed off to the flush workers.
+type flushJob struct {
+	batch  *pendingBatch
+	reason FlushReason
+}
+
+// Start subscribes to the topics, which may contain wildcards, and runs the main
+// consumer loop. It blocks until the context is canceled. The topics can be
+// changed with SetTopics once Subscribed is closed.
+func (p *BatchProcessor) Start(ctx context.Context, topics ...string) error {
+	subs, err := NewSubscriptions(ctx, p.consumer, p.queueGroup, topics)
 	if err != nil {
 		return err
 	}
+	msgCh := subs.Messages()
+	p.subscriptions.Store(subs)
+	close(p.subscribed)
 
-	batch := make([][]byte, 0, batchSize) // Preallocate slice for batch
-	messages := make([]interfaces.Message, 0, batchSize)
+	// A batch holds one of the maxInFlight slots from the moment it is taken
+	// until it has been written. Batches beyond the ones being written by
+	// workers wait in the queue; when every slot is taken, the loop below
+	// blocks, which applies backpressure to the consumer.
+	slots := make(chan struct{}, p.maxInFlight)
+	queue := make(chan flushJob, p.maxInFlight)
+	var workers sync.WaitGroup
+	for i := 0; i < p.concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range queue {
+				// processBatch logs failures and settles the messages itself,
+				// so the worker just moves on to the next batch.
+				_ = p.processBatch(ctx, job.batch, job.reason)
+				p.stats.inFlight.Add(-1)
+				<-slots
+			}
+		}()
+	}
 
-	ticker := time.NewTicker(batchTimeout)
-	defer ticker.Stop()
+	batch := &pendingBatch{}
 
-	log.Println("Batch processor started. Waiting for messages...")
+	// The age timer runs only while the batch holds records, measuring the
+	// age of the oldest one.
+	ageTimer := time.NewTimer(time.Hour)
+	ageTimer.Stop()
+	defer ageTimer.Stop()
+	var ageC <-chan time.Time
+
+	// take resets the batch being accumulated and returns the previous one.
+	take := func() *pendingBatch {
+		// Before Go 1.23 Stop does not drain a tick that has already fired,
+		// which would flush the next batch as soon as its timer is re-armed.
+		if !ageTimer.Stop() {
+			select {
+			case <-ageTimer.C:
+			default:
+			}
+		}
+		ageC = nil
+		current := batch
+		batch = &pendingBatch{}
+		return current
+	}
+
+	flush := func(reason FlushReason) {
+		if batch.len() == 0 {
+			take()
+			return
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			p.logger.Warn("All flush slots busy, pausing consumption", "max_in_flight", p.maxInFlight)
+			p.stats.backpressure.Add(1)
+			slots <- struct{}{}
+		}
+		p.stats.inFlight.Add(1)
+		queue <- flushJob{batch: take(), reason: reason}
+	}
+
+	// stop waits for the in-flight batches and then writes the final batch.
+	// Since ctx may be done, the final batch may get a single write attempt.
+	stop := func(reason FlushReason) error {
+		final := take()
+		close(queue)
+		workers.Wait()
+		return p.processBatch(ctx, final, reason)
+	}
+
+	p.logger.Info("Batch processor started, waiting for messages")
 
 	for {
 		select {
 		case msg, ok := <-msgCh:
+			if !ok && ctx.Err() != nil {
+				// The subscriptions close the channel once ctx is done too.
+				p.logger.Info("Shutdown signal received, processing final batch")
+				return stop(FlushShutdown)
+			}
 			if !ok {
-				log.Println("Message channel closed.")
+				p.logger.Info("Message channel closed")
 				// Channel is closed - process final batch and exit
-				return p.processBatch(&batch, &messages)
+				return stop(FlushDrained)
 			}
 
-			batch = append(batch, msg.Data())
-			messages = append(messages, msg)
+			limits := p.BatchLimits()
 
-			if len(batch) >= batchSize {
-				log.Printf("Flushing batch: size limit reached (%d)", len(batch))
-				if err := p.processBatch(&batch, &messages); err != nil {
-					log.Printf("ERROR processing batch: %v", err)
-					// Note: processBatch messages NACKing, so we just log and continue.
-				}
-				ticker.Reset(batchTimeout)
+			// Flush first if this record would push the batch over the byte limit.
+			if limits.MaxBytes > 0 && batch.len() > 0 && batch.bytes+len(msg.Data()) > limits.MaxBytes {
+				flush(FlushMaxBytes)
 			}
 
-		case <-ticker.C:
-			if len(batch) > 0 {
-				log.Printf("Flushing batch: timeout reached (%d)", len(batch))
-				if err := p.processBatch(&batch, &messages); err != nil {
-					log.Printf("ERROR processing batch: %v", err)
-				}
+			batch.add(msg)
+			p.stats.received.Add(1)
+			if batch.len() == 1 && limits.MaxAge > 0 {
+				ageTimer.Reset(limits.MaxAge)
+				ageC = ageTimer.C
 			}
 
+			var reason FlushReason
+			switch {
+			case limits.MaxRecords > 0 && batch.len() >= limits.MaxRecords:
+				reason = FlushMaxRecords
+			case limits.MaxBytes > 0 && batch.bytes >= limits.MaxBytes:
+				reason = FlushMaxBytes
+			}
+			if reason != "" {
+				flush(reason)
+			}
+
+		case <-ageC:
+			flush(FlushMaxAge)
+
 		case <-ctx.Done():
-			log.Println("Shutdown signal received. Processing final batch...")
+			p.logger.Info("Shutdown signal received, processing final batch")
 			// Context canceled, process final batch and exit
-			return p.processBatch(&batch, &messages)
+			return stop(FlushShutdown)
 		}
 	}
 }
 
-// processBatch sends the batch to the metrics ingestion service.
-func (p *BatchProcessor) processBatch(batch *[][]byte, messages *[]interfaces.Message) error {
-	if len(*batch) == 0 {
+// processBatch writes the batch to the sink and acknowledges its messages.
+// Failed writes are retried according to the retry policy until ctx is done.
+func (p *BatchProcessor) processBatch(ctx context.Context, batch *pendingBatch, reason FlushReason) error {
+	if batch.len() == 0 {
 		return nil // Nothing to process
 	}
 
+	p.logger.Debug("Flushing batch", "reason", reason, "messages", batch.len(), "bytes", batch.bytes)
+	p.stats.recordFlush(reason, batch.len())
+
+	span := startFlushSpan(batch, reason)
+	defer span.End()
+
 	// 1. Attempt to post data with retries
-	err := p.postBatch(*batch)
+	start := time.Now()
+	err := p.retry.Do(ctx, func(attempt int) error {
+		if attempt > 1 {
+			p.logger.Warn("Retrying batch", "messages", batch.len(), "attempt", attempt)
+			p.stats.retries.Add(1)
+			// Keep the broker from redelivering messages we are still working on.
+			inProgressAll(batch.messages, p.logger)
+		}
+		return p.postBatch(batch.records)
+	})
+	if p.metrics != nil {
+		p.metrics.observeFlush(batch.len(), time.Since(start), err)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
 
 	if err == nil {
-		// 2. SUCCESS
-		log.Printf("Successfully posted %d messages.", len(*messages))
-	} else {
+		// 2. SUCCESS: only now may the broker forget the messages
+		p.logger.Info("Posted batch", "messages", batch.len(), "reason", reason)
+		p.stats.written.Add(uint64(batch.len()))
+		ackAll(batch.messages, p.logger)
+	} else if p.deadLetter != nil && (ctx.Err() == nil || !IsRetryable(err)) {
 		// 3. FAILURE (send to DLQ)
-		log.Printf("Failed to post batch %d messages after retries: %v.", len(*messages), err)
+		p.logger.Error("Failed to post batch, sending it to the dead-letter subject", "messages", batch.len(), "error", err)
+		p.stats.failed.Add(uint64(batch.len()))
+		p.deadLetterAll(batch.messages, err)
+	} else {
+		// 3. FAILURE without DLQ, or interrupted by shutdown: let the broker redeliver
+		p.logger.Error("Failed to post batch", "messages", batch.len(), "error", err)
+		p.stats.failed.Add(uint64(batch.len()))
+		nakAll(batch.messages, nakDelay, p.logger)
 	}
 
-	// 4. Clear local buffers
-	*batch = make([][]byte, 0, batchSize)
-	*messages = make([]interfaces.Message, 0, batchSize)
 	return err // Return the error, if any, to the caller
 }
 
+// ackAll acknowledges every message of a successfully written batch.
+func ackAll(messages []interfaces.Message, logger *slog.Logger) {
+	for _, msg := range messages {
+		if err := msg.Ack(); err != nil {
+			logger.Warn("Failed to ack message", "subject", msg.Subject(), "error", err)
+		}
+	}
+}
+
+// nakAll asks the broker to redeliver every message of a failed batch after the delay.
+func nakAll(messages []interfaces.Message, delay time.Duration, logger *slog.Logger) {
+	for _, msg := range messages {
+		if err := msg.Nak(delay); err != nil {
+			logger.Warn("Failed to nak message", "subject", msg.Subject(), "error", err)
+		}
+	}
+}
+
+// inProgressAll resets the redelivery timer of every message of a batch being retried.
+func inProgressAll(messages []interfaces.Message, logger *slog.Logger) {
+	for _, msg := range messages {
+		if err := msg.InProgress(); err != nil {
+			logger.Warn("Failed to mark message in progress", "subject", msg.Subject(), "error", err)
+		}
+	}
+}
+
+// postBatch performs a single write attempt. The write is not bound to the
+// processor's context, so a batch being written during shutdown can complete;
+// sinks are expected to enforce their own timeouts.
 func (p *BatchProcessor) postBatch(batch [][]byte) error {
-	for _, bytes := range batch {
-		log.Printf("Posting data: %s", string(bytes))
-		// TODO: Implement actual posting logic here
+	return p.sink.Write(context.Background(), batch)
+}
 
-		// Simulate network call latency
-		time.Sleep(1 * time.Millisecond)
+// maxFlushSpanLinks caps the number of records a flush span links to, matching
+// the SDK's default limit on links per span.
+const maxFlushSpanLinks = 128
+
+// startFlushSpan starts the span of writing a batch. Since a batch gathers
+// records from many traces, the span starts a trace of its own and links to
+// the publish spans of the records it writes.
+func startFlushSpan(batch *pendingBatch, reason FlushReason) trace.Span {
+	var links []trace.Link
+	for _, msg := range batch.messages {
+		if len(links) == maxFlushSpanLinks {
+			break
+		}
+		if sc := ExtractTraceContext(msg); sc.IsValid() {
+			links = append(links, trace.Link{SpanContext: sc})
+		}
 	}
-	return nil
+
+	_, span := Tracer().Start(context.Background(), "flush batch",
+		trace.WithNewRoot(),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			semconv.MessagingBatchMessageCount(batch.len()),
+			attribute.Int("batch.bytes", batch.bytes),
+			attribute.String("batch.flush_reason", string(reason)),
+		),
+	)
+	return span
 }
@@ -0,0 +1,355 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"play.ground/generic-data-collector/internal/interfaces"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+func TestBatchProcessor_AcksAfterFlush(t *testing.T) {
+	mockConsumer := services.NewMockConsumer()
+	mockSink := services.NewMockSink()
+	processor := services.NewBatchProcessor(mockConsumer, mockSink,
+		services.WithBatchLimits(services.BatchLimits{MaxRecords: 10}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- processor.Start(ctx, "metrics")
+	}()
+
+	messages := make([]*services.MockMessage, 10)
+	for i := range messages {
+		messages[i] = services.NewMockMessage([]byte(fmt.Sprintf(`{"value": %d}`, i)))
+		mockConsumer.SendMessage(messages[i])
+	}
+
+	// A full batch is flushed right away, acknowledging every message.
+	require.Eventually(t, func() bool {
+		for _, msg := range messages {
+			if !msg.Acked() {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 5*time.Millisecond)
+
+	require.Len(t, mockSink.Batches(), 1)
+	assert.Len(t, mockSink.Batches()[0], 10)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestBatchProcessor_NaksOnSinkFailure(t *testing.T) {
+	mockConsumer := services.NewMockConsumer()
+	mockSink := services.NewMockSink()
+	mockSink.WriteErr = errors.New("sink unavailable")
+	processor := services.NewBatchProcessor(mockConsumer, mockSink,
+		services.WithBatchLimits(services.BatchLimits{MaxRecords: 10}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- processor.Start(ctx, "metrics")
+	}()
+
+	msg := services.NewMockMessage([]byte(`{"value": 1}`))
+	mockConsumer.SendMessage(msg)
+
+	// The final batch is flushed on shutdown and fails.
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	assert.Error(t, <-done)
+
+	assert.True(t, msg.Naked())
+	assert.False(t, msg.Acked())
+}
+
+func TestBatchProcessor_RetriesSinkWrites(t *testing.T) {
+	mockConsumer := services.NewMockConsumer()
+	mockSink := services.NewMockSink()
+	mockSink.WriteErr = errors.New("sink unavailable")
+	mockSink.FailWrites = 2
+	processor := services.NewBatchProcessor(mockConsumer, mockSink,
+		services.WithBatchLimits(services.BatchLimits{MaxRecords: 10}),
+		services.WithRetryPolicy(fastRetryPolicy(3)),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- processor.Start(ctx, "metrics")
+	}()
+
+	messages := make([]*services.MockMessage, 10)
+	for i := range messages {
+		messages[i] = services.NewMockMessage([]byte(`{}`))
+		mockConsumer.SendMessage(messages[i])
+	}
+
+	require.Eventually(t, func() bool { return messages[9].Acked() }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 3, mockSink.Writes())
+	assert.Equal(t, 2, messages[0].InProgressCount())
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestBatchProcessor_DeadLettersExhaustedBatch(t *testing.T) {
+	mockConsumer := services.NewMockConsumer()
+	mockSink := services.NewMockSink()
+	mockSink.WriteErr = errors.New("sink unavailable")
+	dlq := services.NewMockProducer()
+	processor := services.NewBatchProcessor(mockConsumer, mockSink,
+		services.WithBatchLimits(services.BatchLimits{MaxRecords: 10}),
+		services.WithRetryPolicy(fastRetryPolicy(2)),
+		services.WithDeadLetter(dlq, "dlq.metrics"),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- processor.Start(ctx, "metrics")
+	}()
+
+	messages := make([]*services.MockMessage, 10)
+	for i := range messages {
+		messages[i] = services.NewMockMessageWithHeader([]byte(fmt.Sprintf(`{"value": %d}`, i)),
+			interfaces.Header{interfaces.HeaderClientID: "dashboard"})
+		mockConsumer.SendMessage(messages[i])
+	}
+
+	require.Eventually(t, func() bool { return messages[9].Terminated() }, time.Second, 5*time.Millisecond)
+
+	published := dlq.Published()
+	require.Len(t, published, 10)
+	assert.Equal(t, "dlq.metrics", published[0].Topic)
+	assert.Equal(t, "dashboard", published[0].Header[interfaces.HeaderClientID])
+
+	var dl services.DeadLetter
+	require.NoError(t, json.Unmarshal(published[0].Data, &dl))
+	assert.Equal(t, "metrics", dl.Topic)
+	assert.Equal(t, "sink unavailable", dl.Error)
+	assert.Equal(t, 2, dl.Attempts)
+	assert.Equal(t, uint64(1), dl.Deliveries)
+	assert.False(t, dl.FirstFailure.IsZero())
+	assert.JSONEq(t, `{"value": 0}`, string(dl.Payload))
+	assert.False(t, messages[0].Naked())
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestBatchProcessor_ShutdownFailureIsRedelivered(t *testing.T) {
+	mockConsumer := services.NewMockConsumer()
+	mockSink := services.NewMockSink()
+	mockSink.WriteErr = errors.New("sink unavailable")
+	dlq := services.NewMockProducer()
+	processor := services.NewBatchProcessor(mockConsumer, mockSink,
+		services.WithDeadLetter(dlq, "dlq.metrics"),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- processor.Start(ctx, "metrics")
+	}()
+
+	msg := services.NewMockMessage([]byte(`{"value": 1}`))
+	mockConsumer.SendMessage(msg)
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	assert.Error(t, <-done)
+
+	// A retryable failure interrupted by shutdown is left to the broker.
+	assert.True(t, msg.Naked())
+	assert.Empty(t, dlq.Published())
+}
+
+func TestBatchProcessor_FlushLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  services.BatchLimits
+		records []string
+		batches []int
+		reason  services.FlushReason
+		flushes uint64
+	}{
+		{
+			name:    "max records",
+			limits:  services.BatchLimits{MaxRecords: 2},
+			records: []string{`{"a":1}`, `{"a":2}`, `{"a":3}`, `{"a":4}`},
+			batches: []int{2, 2},
+			reason:  services.FlushMaxRecords,
+			flushes: 2,
+		},
+		{
+			// 7 bytes per record: the third record would exceed 20 bytes.
+			name:    "max bytes",
+			limits:  services.BatchLimits{MaxBytes: 20},
+			records: []string{`{"a":1}`, `{"a":2}`, `{"a":3}`},
+			batches: []int{2},
+			reason:  services.FlushMaxBytes,
+			flushes: 1,
+		},
+		{
+			name:    "max age",
+			limits:  services.BatchLimits{MaxRecords: 100, MaxAge: 20 * time.Millisecond},
+			records: []string{`{"a":1}`, `{"a":2}`},
+			batches: []int{2},
+			reason:  services.FlushMaxAge,
+			flushes: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockConsumer := services.NewMockConsumer()
+			mockSink := services.NewMockSink()
+			processor := services.NewBatchProcessor(mockConsumer, mockSink,
+				services.WithBatchLimits(tt.limits),
+			)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() {
+				done <- processor.Start(ctx, "metrics")
+			}()
+
+			for _, record := range tt.records {
+				mockConsumer.SendMessage(services.NewMockMessage([]byte(record)))
+			}
+
+			require.Eventually(t, func() bool {
+				return len(mockSink.Batches()) == len(tt.batches)
+			}, time.Second, 5*time.Millisecond)
+			for i, size := range tt.batches {
+				assert.Len(t, mockSink.Batches()[i], size)
+			}
+			assert.Equal(t, tt.flushes, processor.Stats().Flushes[tt.reason])
+
+			cancel()
+			assert.NoError(t, <-done)
+		})
+	}
+}
+
+func TestBatchProcessor_BoundedInFlightFlushes(t *testing.T) {
+	mockConsumer := services.NewMockConsumer()
+	mockSink := services.NewMockSink()
+	mockSink.Block = make(chan struct{})
+	processor := services.NewBatchProcessor(mockConsumer, mockSink,
+		services.WithBatchLimits(services.BatchLimits{MaxRecords: 1}),
+		services.WithFlushConcurrency(1, 2),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- processor.Start(ctx, "metrics")
+	}()
+
+	for i := 0; i < 4; i++ {
+		mockConsumer.SendMessage(services.NewMockMessage([]byte(`{}`)))
+	}
+
+	// One batch is being written and one is queued; the third waits for a
+	// slot and blocks the loop, so the fourth message is not consumed.
+	require.Eventually(t, func() bool {
+		return processor.Stats().Backpressure >= 1
+	}, time.Second, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	stats := processor.Stats()
+	assert.LessOrEqual(t, stats.InFlight, int64(2))
+	assert.Equal(t, uint64(3), stats.Received)
+
+	// Once the sink recovers, consumption resumes.
+	close(mockSink.Block)
+	require.Eventually(t, func() bool {
+		return len(mockSink.Batches()) == 4
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Equal(t, int64(0), processor.Stats().InFlight)
+}
+
+func TestBatchProcessor_SizeFlushRacingAgeTimer(t *testing.T) {
+	mockConsumer := services.NewMockConsumer()
+	mockSink := services.NewMockSink()
+	mockSink.Block = make(chan struct{})
+	processor := services.NewBatchProcessor(mockConsumer, mockSink,
+		services.WithBatchLimits(services.BatchLimits{MaxRecords: 2, MaxAge: 50 * time.Millisecond}),
+		services.WithFlushConcurrency(1, 1),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- processor.Start(ctx, "metrics")
+	}()
+
+	// The first batch occupies the only flush slot, so the size flush of the
+	// second one waits while its age timer expires.
+	for i := 0; i < 4; i++ {
+		mockConsumer.SendMessage(services.NewMockMessage([]byte(`{}`)))
+	}
+	require.Eventually(t, func() bool {
+		return processor.Stats().Backpressure >= 1
+	}, time.Second, 5*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	close(mockSink.Block)
+	require.Eventually(t, func() bool {
+		return len(mockSink.Batches()) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	// The expired timer must not flush the next batch early.
+	mockConsumer.SendMessage(services.NewMockMessage([]byte(`{}`)))
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, mockSink.Batches(), 2)
+	assert.Zero(t, processor.Stats().Flushes[services.FlushMaxAge])
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestBatchProcessor_Drain(t *testing.T) {
+	broker, err := services.NewMemoryBroker(services.MemoryBrokerConfig{})
+	require.NoError(t, err)
+	defer broker.Close()
+	mockSink := services.NewMockSink()
+	processor := services.NewBatchProcessor(broker, mockSink,
+		services.WithBatchLimits(services.BatchLimits{MaxRecords: 10}),
+	)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- processor.Start(context.Background(), "metrics")
+	}()
+	<-processor.Subscribed()
+	require.NoError(t, broker.Publish("metrics", []byte(`{"value":1}`), nil))
+	require.NoError(t, broker.Publish("metrics", []byte(`{"value":2}`), nil))
+
+	// The messages already received are written before Start returns.
+	require.NoError(t, processor.Drain())
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("processor did not return")
+	}
+	require.Len(t, mockSink.Batches(), 1)
+	assert.Len(t, mockSink.Batches()[0], 2)
+	assert.Equal(t, uint64(1), processor.Stats().Flushes[services.FlushDrained])
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// BatchStats holds the BatchProcessor counters.
+type BatchStats struct {
+	received atomic.Uint64
+	written  atomic.Uint64
+	failed   atomic.Uint64
+	retries  atomic.Uint64
+
+	inFlight     atomic.Int64
+	backpressure atomic.Uint64
+
+	mu      sync.Mutex
+	flushes map[FlushReason]uint64
+	batched uint64
+}
+
+// BatchStatsSnapshot is a point-in-time copy of the BatchProcessor counters.
+type BatchStatsSnapshot struct {
+	// Received is the number of messages taken from the consumer.
+	Received uint64
+	// Batched is the number of messages handed to the sink in a flush.
+	Batched uint64
+	// Written is the number of messages successfully written to the sink.
+	Written uint64
+	// Failed is the number of messages whose batch could not be written.
+	Failed uint64
+	// Retries is the number of write attempts beyond the first.
+	Retries uint64
+	// Flushes counts flushes by the reason that triggered them.
+	Flushes map[FlushReason]uint64
+	// InFlight is the number of batches being written or waiting for a flush worker.
+	InFlight int64
+	// Backpressure counts the times consumption paused because all flush slots were busy.
+	Backpressure uint64
+}
+
+func newBatchStats() *BatchStats {
+	return &BatchStats{flushes: make(map[FlushReason]uint64)}
+}
+
+func (s *BatchStats) recordFlush(reason FlushReason, size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushes[reason]++
+	s.batched += uint64(size)
+}
+
+func (s *BatchStats) snapshot() BatchStatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flushes := make(map[FlushReason]uint64, len(s.flushes))
+	for reason, n := range s.flushes {
+		flushes[reason] = n
+	}
+	return BatchStatsSnapshot{
+		Received: s.received.Load(),
+		Batched:  s.batched,
+		Written:  s.written.Load(),
+		Failed:   s.failed.Load(),
+		Retries:  s.retries.Load(),
+		Flushes:  flushes,
+
+		InFlight:     s.inFlight.Load(),
+		Backpressure: s.backpressure.Load(),
+	}
+}
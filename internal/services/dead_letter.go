@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"play.ground/generic-data-collector/internal/interfaces"
+)
+
+// deadLetterPublishTimeout bounds how long publishing a single dead letter may take.
+const deadLetterPublishTimeout = 5 * time.Second
+
+// DeadLetter wraps a record that could not be delivered to the sink, together
+// with the metadata needed to inspect and replay it.
+type DeadLetter struct {
+	// Topic is the topic the record was originally consumed from.
+	Topic string `json:"topic"`
+	// Error is the error returned by the last delivery attempt.
+	Error string `json:"error"`
+	// Attempts is the number of write attempts made for the record's batch.
+	Attempts int `json:"attempts"`
+	// Deliveries is the number of times the broker delivered the record.
+	Deliveries   uint64    `json:"deliveries"`
+	FirstFailure time.Time `json:"first_failure"`
+	LastFailure  time.Time `json:"last_failure"`
+	// Payload holds the original record when it is valid JSON.
+	Payload json.RawMessage `json:"payload,omitempty"`
+	// RawPayload holds the original record, base64-encoded, when it is not valid JSON.
+	RawPayload []byte `json:"raw_payload,omitempty"`
+}
+
+// NewDeadLetter wraps a record that failed with err. If err is a *RetryError,
+// its attempt count and failure times are recorded.
+func NewDeadLetter(topic string, data []byte, deliveries uint64, err error) DeadLetter {
+	now := time.Now()
+	dl := DeadLetter{
+		Topic:        topic,
+		Error:        err.Error(),
+		Attempts:     1,
+		Deliveries:   deliveries,
+		FirstFailure: now,
+		LastFailure:  now,
+	}
+
+	var retryErr *RetryError
+	if errors.As(err, &retryErr) {
+		dl.Error = retryErr.Err.Error()
+		dl.Attempts = retryErr.Attempts
+		dl.FirstFailure = retryErr.FirstFailure
+		dl.LastFailure = retryErr.LastFailure
+	}
+
+	if json.Valid(data) {
+		dl.Payload = data
+	} else {
+		dl.RawPayload = data
+	}
+	return dl
+}
+
+// deadLetterAll publishes every message of a failed batch to the dead-letter
+// subject. Dead-lettered messages are terminated so the broker does not redeliver
+// them; messages that could not be dead-lettered are negatively acknowledged.
+func (p *BatchProcessor) deadLetterAll(messages []interfaces.Message, cause error) {
+	failed := 0
+	for _, msg := range messages {
+		if err := p.publishDeadLetter(msg, cause); err != nil {
+			p.logger.Error("Failed to publish message to dead-letter subject", "subject", msg.Subject(), "dead_letter_subject", p.deadLetterSubject, "error", err)
+			failed++
+			if err := msg.Nak(nakDelay); err != nil {
+				p.logger.Warn("Failed to nak message", "subject", msg.Subject(), "error", err)
+			}
+			continue
+		}
+		if err := msg.Term(); err != nil {
+			p.logger.Warn("Failed to terminate message", "subject", msg.Subject(), "error", err)
+		}
+	}
+	p.logger.Warn("Sent messages to dead-letter subject", "sent", len(messages)-failed, "messages", len(messages), "dead_letter_subject", p.deadLetterSubject)
+}
+
+func (p *BatchProcessor) publishDeadLetter(msg interfaces.Message, cause error) error {
+	payload, err := json.Marshal(NewDeadLetter(msg.Subject(), msg.Data(), msg.NumDelivered(), cause))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deadLetterPublishTimeout)
+	defer cancel()
+	return p.deadLetter.PublishSync(ctx, p.deadLetterSubject, payload, msg.Header())
+}
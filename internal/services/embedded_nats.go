@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// embeddedNATSStartTimeout bounds how long starting the embedded server may take.
+const embeddedNATSStartTimeout = 10 * time.Second
+
+// EmbeddedNATSConfig holds the settings of a NATS server running inside the process.
+type EmbeddedNATSConfig struct {
+	// Host is the interface the server listens on.
+	Host string
+	// Port is the client port; -1 picks a random free port.
+	Port int
+	// JetStream enables JetStream.
+	JetStream bool
+	// StoreDir is where JetStream stores its streams; empty means a new
+	// temporary directory, removed on shutdown.
+	StoreDir string
+}
+
+// EmbeddedNATS is a NATS server running inside the process, so that the real
+// NATS and JetStream code paths can run without an external server.
+type EmbeddedNATS struct {
+	server *server.Server
+	// tempDir is the store directory created for the server, if any.
+	tempDir string
+}
+
+// StartEmbeddedNATS starts a NATS server and waits until it accepts connections.
+func StartEmbeddedNATS(cfg EmbeddedNATSConfig) (*EmbeddedNATS, error) {
+	// Without a store directory the server would use a fixed directory shared
+	// by every process and run.
+	var tempDir string
+	if cfg.JetStream && cfg.StoreDir == "" {
+		dir, err := os.MkdirTemp("", "nats-jetstream-")
+		if err != nil {
+			return nil, fmt.Errorf("embedded nats: %w", err)
+		}
+		tempDir, cfg.StoreDir = dir, dir
+	}
+
+	opts := &server.Options{
+		ServerName: "embedded",
+		Host:       cfg.Host,
+		Port:       cfg.Port,
+		JetStream:  cfg.JetStream,
+		StoreDir:   cfg.StoreDir,
+		// The server logs nothing; its clients report connection problems.
+		NoLog:  true,
+		NoSigs: true,
+	}
+	ns, err := server.NewServer(opts)
+	if err != nil {
+		removeTempDir(tempDir)
+		return nil, fmt.Errorf("embedded nats: %w", err)
+	}
+	n := &EmbeddedNATS{server: ns, tempDir: tempDir}
+	ns.Start()
+	if !ns.ReadyForConnections(embeddedNATSStartTimeout) {
+		n.Shutdown()
+		return nil, errors.New("embedded nats: server not ready for connections")
+	}
+	return n, nil
+}
+
+// ClientURL returns the URL clients connect to.
+func (n *EmbeddedNATS) ClientURL() string {
+	return n.server.ClientURL()
+}
+
+// Shutdown stops the server, waits until it has stopped, and removes the
+// temporary store directory. Shutting it down again has no effect.
+func (n *EmbeddedNATS) Shutdown() {
+	n.server.Shutdown()
+	n.server.WaitForShutdown()
+	removeTempDir(n.tempDir)
+}
+
+func removeTempDir(dir string) {
+	if dir != "" {
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"play.ground/generic-data-collector/internal/interfaces"
+)
+
+const (
+	// HealthCheckTimeout bounds how long a single dependency check may take.
+	HealthCheckTimeout = 2 * time.Second
+
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+)
+
+// HealthResult is the outcome of checking a single dependency.
+type HealthResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// LatencyMS is how long the check took, in milliseconds.
+	LatencyMS int64 `json:"latency_ms"`
+}
+
+// HealthReport is the outcome of checking all dependencies of a process.
+type HealthReport struct {
+	Status string                  `json:"status"`
+	Checks map[string]HealthResult `json:"checks"`
+}
+
+// Healthy reports whether every dependency is healthy.
+func (r HealthReport) Healthy() bool {
+	return r.Status == HealthStatusOK
+}
+
+// CheckHealth checks the dependencies in parallel, giving each of them up to
+// HealthCheckTimeout to respond.
+func CheckHealth(ctx context.Context, checks map[string]interfaces.HealthChecker) HealthReport {
+	report := HealthReport{Status: HealthStatusOK, Checks: make(map[string]HealthResult, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, checker := range checks {
+		wg.Add(1)
+		go func(name string, checker interfaces.HealthChecker) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := checker.Health(ctx)
+			result := HealthResult{Status: HealthStatusOK, LatencyMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				result.Status = HealthStatusUnavailable
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if err != nil {
+				report.Status = HealthStatusUnavailable
+			}
+		}(name, checker)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// connectionHealth reports an error unless the NATS connection is established.
+func connectionHealth(conn *nats.Conn) error {
+	if status := conn.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("nats: connection is %s", status)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"play.ground/generic-data-collector/internal/interfaces"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+func TestCheckHealth(t *testing.T) {
+	consumer := services.NewMockConsumer()
+	sink := services.NewMockSink()
+
+	report := services.CheckHealth(context.Background(), map[string]interfaces.HealthChecker{
+		"broker": consumer,
+		"sink":   sink,
+	})
+	assert.True(t, report.Healthy())
+	assert.Equal(t, services.HealthStatusOK, report.Checks["broker"].Status)
+	assert.Equal(t, services.HealthStatusOK, report.Checks["sink"].Status)
+
+	sink.HealthErr = errors.New("disk full")
+	report = services.CheckHealth(context.Background(), map[string]interfaces.HealthChecker{
+		"broker": consumer,
+		"sink":   sink,
+	})
+	assert.False(t, report.Healthy())
+	assert.Equal(t, services.HealthStatusUnavailable, report.Status)
+	assert.Equal(t, services.HealthStatusOK, report.Checks["broker"].Status)
+	assert.Equal(t, services.HealthStatusUnavailable, report.Checks["sink"].Status)
+	assert.Equal(t, "disk full", report.Checks["sink"].Error)
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const defaultHTTPSinkTimeout = 10 * time.Second
+
+// HTTPSink implements the Sink interface by POSTing each batch as
+// newline-delimited JSON to a bulk ingestion endpoint.
+type HTTPSink struct {
+	url       string
+	healthURL string
+	client    *http.Client
+}
+
+// NewHTTPSink creates a sink posting to url. If healthURL is set, Health
+// issues a GET request against it.
+func NewHTTPSink(url, healthURL string, timeout time.Duration) (*HTTPSink, error) {
+	if url == "" {
+		return nil, errors.New("http sink: url is required")
+	}
+	if timeout <= 0 {
+		timeout = defaultHTTPSinkTimeout
+	}
+	return &HTTPSink{
+		url:       url,
+		healthURL: healthURL,
+		client:    &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Write posts the batch and treats any non-2xx response as a failure. Client
+// errors other than 408 and 429 are permanent, since resending the same batch
+// would be rejected again.
+func (s *HTTPSink) Write(ctx context.Context, batch [][]byte) error {
+	var body bytes.Buffer
+	for _, record := range batch {
+		body.Write(record)
+		body.WriteByte('\n')
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	return s.do(req)
+}
+
+// Health checks the health endpoint, if one is configured.
+func (s *HTTPSink) Health(ctx context.Context) error {
+	if s.healthURL == "" {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.healthURL, nil)
+	if err != nil {
+		return err
+	}
+	return s.do(req)
+}
+
+func (s *HTTPSink) do(req *http.Request) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("http sink: %s %s returned %d: %s", req.Method, req.URL, resp.StatusCode, bytes.TrimSpace(msg))
+		if isPermanentStatus(resp.StatusCode) {
+			return Permanent(err)
+		}
+		return err
+	}
+	// Drain the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Close releases idle connections.
+func (s *HTTPSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func isPermanentStatus(status int) bool {
+	return status >= 400 && status < 500 &&
+		status != http.StatusRequestTimeout && status != http.StatusTooManyRequests
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// InFlight tracks background operations, such as asynchronous publishes, so
+// that shutdown can wait for them. The zero value is ready to use.
+type InFlight struct {
+	mu sync.Mutex
+	n  int
+	// idle is closed when the count drops to zero; it is only created while
+	// someone is waiting.
+	idle chan struct{}
+}
+
+// Go runs fn in a new goroutine and tracks it until it returns.
+func (f *InFlight) Go(fn func()) {
+	f.mu.Lock()
+	f.n++
+	f.mu.Unlock()
+
+	go func() {
+		defer f.done()
+		fn()
+	}()
+}
+
+func (f *InFlight) done() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.n--
+	if f.n == 0 && f.idle != nil {
+		close(f.idle)
+		f.idle = nil
+	}
+}
+
+// Len returns the number of operations still running.
+func (f *InFlight) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.n
+}
+
+// Wait blocks until no operation is running or the context is done. Unlike a
+// sync.WaitGroup, operations may still be started while waiting.
+func (f *InFlight) Wait(ctx context.Context) error {
+	f.mu.Lock()
+	if f.n == 0 {
+		f.mu.Unlock()
+		return nil
+	}
+	if f.idle == nil {
+		f.idle = make(chan struct{})
+	}
+	idle := f.idle
+	f.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d operations still in flight: %w", f.Len(), ctx.Err())
+	}
+}
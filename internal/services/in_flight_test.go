@@ -0,0 +1,29 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+func TestInFlight_Wait(t *testing.T) {
+	var inFlight services.InFlight
+	assert.NoError(t, inFlight.Wait(context.Background()))
+
+	release := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		inFlight.Go(func() { <-release })
+	}
+	assert.Equal(t, 3, inFlight.Len())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, inFlight.Wait(ctx), context.DeadlineExceeded)
+
+	close(release)
+	assert.NoError(t, inFlight.Wait(context.Background()))
+	assert.Equal(t, 0, inFlight.Len())
+}
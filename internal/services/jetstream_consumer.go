@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"play.ground/generic-data-collector/internal/interfaces"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// JetStreamConsumer implements the Consumer interface with durable JetStream pull
+// consumers. Messages stay pending on the server until they are acknowledged, so
+// anything not acked before a crash is redelivered after a restart.
+type JetStreamConsumer struct {
+	conn *nats.Conn
+	// closed is closed once the connection is, after draining.
+	closed <-chan struct{}
+	js     jetstream.JetStream
+	cfg    JetStreamConfig
+	subs   []*jetStreamSubscription
+	mu     sync.Mutex
+}
+
+// jetStreamSubscription forwards messages from a pull consumer to a Go channel.
+type jetStreamSubscription struct {
+	topic      string
+	consumeCtx jetstream.ConsumeContext
+	dataCh     chan interfaces.Message
+	done       chan struct{}
+	closed     bool
+	mu         sync.RWMutex
+}
+
+// NewJetStreamConsumer connects to the given NATS URL and makes sure the configured
+// stream exists before returning the consumer.
+func NewJetStreamConsumer(url string, cfg JetStreamConfig) (interfaces.Consumer, error) {
+	nc, closed, err := connectDrainable(url)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultJetStreamAckTimeout)
+	defer cancel()
+	if err := ensureStream(ctx, js, cfg); err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	return &JetStreamConsumer{conn: nc, closed: closed, js: js, cfg: cfg}, nil
+}
+
+// Subscribe creates or updates the durable consumer for the topic and returns a Go
+// channel from which messages can be read. Messages must be acknowledged by the caller.
+// All workers configured with the same durable name share the consumer's messages.
+func (c *JetStreamConsumer) Subscribe(topic string) (<-chan interfaces.Message, error) {
+	return c.subscribe(topic, c.cfg.Durable)
+}
+
+// QueueSubscribe is like Subscribe, but the durable consumer is named after the
+// queue group, so the workers of each group share one copy of the messages.
+func (c *JetStreamConsumer) QueueSubscribe(topic, queue string) (<-chan interfaces.Message, error) {
+	return c.subscribe(topic, queue)
+}
+
+func (c *JetStreamConsumer) subscribe(topic, durable string) (<-chan interfaces.Message, error) {
+	if durable == "" {
+		return nil, errors.New("jetstream: durable consumer name is required")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultJetStreamAckTimeout)
+	defer cancel()
+
+	consumer, err := c.js.CreateOrUpdateConsumer(ctx, c.cfg.Stream, jetstream.ConsumerConfig{
+		Durable:       durableName(durable, topic),
+		FilterSubject: topic,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       c.cfg.AckWait,
+		MaxDeliver:    c.cfg.MaxDeliver,
+		MaxAckPending: c.cfg.MaxAckPending,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &jetStreamSubscription{
+		topic:  topic,
+		dataCh: make(chan interfaces.Message, 64),
+		done:   make(chan struct{}),
+	}
+
+	sub.consumeCtx, err = consumer.Consume(sub.handle)
+	if err != nil {
+		return nil, err
+	}
+	c.subs = append(c.subs, sub)
+
+	return sub.dataCh, nil
+}
+
+// handle is the pull consumer callback; it blocks until the message is handed
+// over or the subscription is closed, which also throttles pulling.
+func (s *jetStreamSubscription) handle(msg jetstream.Msg) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return
+	}
+	select {
+	case s.dataCh <- NewJetStreamMessage(msg):
+	case <-s.done:
+		// Not acked, so the server redelivers it once AckWait expires.
+	}
+}
+
+// close stops pulling and closes the data channel once no callback is running.
+func (s *jetStreamSubscription) close() {
+	s.consumeCtx.Stop()
+	close(s.done)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	close(s.dataCh)
+}
+
+// Unsubscribe stops the subscriptions to the topic and closes their channels.
+// The durable consumers stay on the server, so subscribing to the topic again
+// resumes from the first message that was not acknowledged.
+func (c *JetStreamConsumer) Unsubscribe(topic string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	kept := c.subs[:0]
+	for _, sub := range c.subs {
+		if sub.topic == topic {
+			sub.close()
+		} else {
+			kept = append(kept, sub)
+		}
+	}
+	c.subs = kept
+	return nil
+}
+
+// durableNameReplacer escapes the characters of subjects that are not allowed
+// in consumer names. Underscores are escaped too, so that distinct topics never
+// share a name.
+var durableNameReplacer = strings.NewReplacer("_", "__", ".", "_d", "*", "_s", ">", "_g")
+
+// durableName derives a valid durable name for a topic, unique to the topic.
+func durableName(base, topic string) string {
+	return base + "_" + durableNameReplacer.Replace(topic)
+}
+
+// Pending returns the number of messages buffered in the subscription channels.
+func (c *JetStreamConsumer) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pending := 0
+	for _, sub := range c.subs {
+		pending += len(sub.dataCh)
+	}
+	return pending
+}
+
+// Health reports an error unless the consumer is connected to the server.
+func (c *JetStreamConsumer) Health(context.Context) error {
+	return connectionHealth(c.conn)
+}
+
+// Close stops all subscriptions and closes the NATS connection once the pending
+// acknowledgements have been flushed.
+func (c *JetStreamConsumer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, sub := range c.subs {
+		sub.close()
+	}
+	c.subs = nil
+
+	return drain(c.conn, c.closed)
+}
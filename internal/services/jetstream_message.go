@@ -0,0 +1,58 @@
+package services
+
+import (
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"play.ground/generic-data-collector/internal/interfaces"
+)
+
+// JetStreamMessage wraps a JetStream message and forwards acknowledgements to the server.
+type JetStreamMessage struct {
+	msg jetstream.Msg
+}
+
+func NewJetStreamMessage(msg jetstream.Msg) interfaces.Message {
+	return JetStreamMessage{msg: msg}
+}
+
+func (m JetStreamMessage) Data() []byte {
+	return m.msg.Data()
+}
+
+func (m JetStreamMessage) Subject() string {
+	return m.msg.Subject()
+}
+
+func (m JetStreamMessage) Header() interfaces.Header {
+	return fromNATSHeader(m.msg.Headers())
+}
+
+func (m JetStreamMessage) Ack() error {
+	return m.msg.Ack()
+}
+
+func (m JetStreamMessage) Nak(delay time.Duration) error {
+	if delay <= 0 {
+		return m.msg.Nak()
+	}
+	return m.msg.NakWithDelay(delay)
+}
+
+func (m JetStreamMessage) Term() error {
+	return m.msg.Term()
+}
+
+func (m JetStreamMessage) InProgress() error {
+	return m.msg.InProgress()
+}
+
+// NumDelivered returns the delivery count reported by the server, or 1 if the
+// message metadata cannot be parsed.
+func (m JetStreamMessage) NumDelivered() uint64 {
+	meta, err := m.msg.Metadata()
+	if err != nil {
+		return 1
+	}
+	return meta.NumDelivered
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"play.ground/generic-data-collector/internal/interfaces"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const defaultJetStreamAckTimeout = 5 * time.Second
+
+// JetStreamConfig describes the stream backing the JetStream producer and the
+// durable consumer reading from it.
+type JetStreamConfig struct {
+	// Stream is the name of the stream that captures the ingested subjects.
+	Stream string
+	// Subjects are the subjects stored by the stream.
+	Subjects []string
+	// Retention is one of "limits", "interest" or "workqueue".
+	Retention string
+	// MaxAge is the maximum age of stored messages; zero means unlimited.
+	MaxAge time.Duration
+	// MaxBytes is the maximum size of the stream; zero means unlimited.
+	MaxBytes int64
+	// Replicas is the number of stream replicas in a clustered deployment.
+	Replicas int
+	// AckTimeout bounds how long Publish waits for the PubAck.
+	AckTimeout time.Duration
+
+	// Durable is the durable consumer name shared by all workers.
+	Durable string
+	// AckWait is how long the server waits for an ack before redelivering a message.
+	AckWait time.Duration
+	// MaxDeliver limits redeliveries of a message; zero means unlimited.
+	MaxDeliver int
+	// MaxAckPending limits the number of unacknowledged messages in flight.
+	MaxAckPending int
+}
+
+// streamConfig converts the configuration into a JetStream stream definition.
+func (c JetStreamConfig) streamConfig() (jetstream.StreamConfig, error) {
+	if c.Stream == "" {
+		return jetstream.StreamConfig{}, errors.New("jetstream: stream name is required")
+	}
+	if len(c.Subjects) == 0 {
+		return jetstream.StreamConfig{}, errors.New("jetstream: at least one subject is required")
+	}
+
+	retention, err := parseRetention(c.Retention)
+	if err != nil {
+		return jetstream.StreamConfig{}, err
+	}
+
+	maxBytes := c.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = -1
+	}
+	replicas := c.Replicas
+	if replicas <= 0 {
+		replicas = 1
+	}
+
+	return jetstream.StreamConfig{
+		Name:      c.Stream,
+		Subjects:  c.Subjects,
+		Retention: retention,
+		MaxAge:    c.MaxAge,
+		MaxBytes:  maxBytes,
+		Replicas:  replicas,
+		Storage:   jetstream.FileStorage,
+	}, nil
+}
+
+func parseRetention(retention string) (jetstream.RetentionPolicy, error) {
+	switch retention {
+	case "", "limits":
+		return jetstream.LimitsPolicy, nil
+	case "interest":
+		return jetstream.InterestPolicy, nil
+	case "workqueue":
+		return jetstream.WorkQueuePolicy, nil
+	default:
+		return 0, fmt.Errorf("jetstream: unknown retention policy %q", retention)
+	}
+}
+
+// JetStreamProducer implements the Producer interface on top of NATS JetStream.
+// Every publish waits for the stream's PubAck, so a nil error means the message
+// has been persisted.
+type JetStreamProducer struct {
+	conn       *nats.Conn
+	closed     <-chan struct{}
+	js         jetstream.JetStream
+	ackTimeout time.Duration
+}
+
+// NewJetStreamProducer connects to the given NATS URL and makes sure the configured
+// stream exists with the configured limits before returning the producer.
+func NewJetStreamProducer(url string, cfg JetStreamConfig) (interfaces.Producer, error) {
+	nc, closed, err := connectDrainable(url)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	ackTimeout := cfg.AckTimeout
+	if ackTimeout <= 0 {
+		ackTimeout = defaultJetStreamAckTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ackTimeout)
+	defer cancel()
+	if err := ensureStream(ctx, js, cfg); err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	return &JetStreamProducer{conn: nc, closed: closed, js: js, ackTimeout: ackTimeout}, nil
+}
+
+// ensureStream creates the stream or updates it to match the configuration.
+func ensureStream(ctx context.Context, js jetstream.JetStream, cfg JetStreamConfig) error {
+	streamCfg, err := cfg.streamConfig()
+	if err != nil {
+		return err
+	}
+	if _, err := js.CreateOrUpdateStream(ctx, streamCfg); err != nil {
+		return fmt.Errorf("jetstream: failed to create stream %q: %w", cfg.Stream, err)
+	}
+	return nil
+}
+
+// Publish sends a message to the topic and waits up to the ack timeout for the PubAck.
+func (p *JetStreamProducer) Publish(topic string, message []byte, header interfaces.Header) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.ackTimeout)
+	defer cancel()
+	return p.PublishSync(ctx, topic, message, header)
+}
+
+// PublishSync sends a message to the topic and waits for the PubAck or for the context to be done.
+func (p *JetStreamProducer) PublishSync(ctx context.Context, topic string, message []byte, header interfaces.Header) error {
+	if _, err := p.js.PublishMsg(ctx, newNATSMsg(topic, message, header)); err != nil {
+		return fmt.Errorf("jetstream: publish to %q not acknowledged: %w", topic, err)
+	}
+	return nil
+}
+
+// Health reports an error unless the producer is connected to the server.
+func (p *JetStreamProducer) Health(context.Context) error {
+	return connectionHealth(p.conn)
+}
+
+// Close drains and closes the NATS connection.
+func (p *JetStreamProducer) Close() error {
+	return drain(p.conn, p.closed)
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+)
+
+// LogSink implements the Sink interface by logging every record.
+// It is meant for development and debugging.
+type LogSink struct {
+	logger *slog.Logger
+}
+
+// NewLogSink creates a new LogSink writing to the logger.
+func NewLogSink(logger *slog.Logger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+// Write logs the size of the batch and, at debug level, each of its records.
+// Records may hold sensitive data, so they are never logged above debug.
+func (s *LogSink) Write(ctx context.Context, batch [][]byte) error {
+	s.logger.Info("Posting batch", "records", len(batch))
+	if !s.logger.Enabled(ctx, slog.LevelDebug) {
+		return nil
+	}
+	for _, record := range batch {
+		s.logger.Debug("Posting record", "data", string(record))
+	}
+	return nil
+}
+
+// Health always reports the sink as healthy.
+func (s *LogSink) Health(context.Context) error {
+	return nil
+}
+
+// Close does nothing.
+func (s *LogSink) Close() error {
+	return nil
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+
+	// Components whose level can be set separately in LogConfig.Components.
+	LogComponentServer = "server"
+	LogComponentHTTP   = "http"
+	LogComponentWorker = "worker"
+	LogComponentBatch  = "batch"
+	LogComponentSink   = "sink"
+)
+
+// LogConfig selects the format of the logs and the minimum level of each component.
+type LogConfig struct {
+	// Level is the minimum level of components without a level of their own:
+	// "debug", "info", "warn" or "error". Empty means "info".
+	Level string
+	// Format is "json" or "text". Empty means "text".
+	Format string
+	// Components maps component names to their own minimum level.
+	Components map[string]string
+}
+
+// Validate reports every invalid setting.
+func (c LogConfig) Validate() error {
+	var errs []error
+	switch strings.ToLower(c.Format) {
+	case "", LogFormatText, LogFormatJSON:
+	default:
+		errs = append(errs, fmt.Errorf("unknown log format %q: expected %q or %q", c.Format, LogFormatJSON, LogFormatText))
+	}
+	if _, err := parseLogLevel(c.Level); err != nil {
+		errs = append(errs, err)
+	}
+	for _, component := range sortedKeys(c.Components) {
+		if _, err := parseLogLevel(c.Components[component]); err != nil {
+			errs = append(errs, fmt.Errorf("component %q: %w", component, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Logging creates the structured loggers of a process. All loggers write to the
+// same output, and each component has its own level, so a noisy component can
+// be turned down without losing the logs of the others.
+type Logging struct {
+	handler slog.Handler
+
+	mu         sync.Mutex
+	level      slog.Level
+	components map[string]slog.Level
+	levels     map[string]*slog.LevelVar
+}
+
+// NewLogging creates the loggers writing to w in the configured format.
+func NewLogging(cfg LogConfig, w io.Writer) (*Logging, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Levels are checked per component, so the handler itself lets everything through.
+	opts := &slog.HandlerOptions{Level: slog.Level(-100)}
+
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", LogFormatText:
+		handler = slog.NewTextHandler(w, opts)
+	case LogFormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	}
+
+	l := &Logging{handler: handler, levels: make(map[string]*slog.LevelVar)}
+	if err := l.SetLevels(cfg.Level, cfg.Components); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// DiscardLogging returns loggers that drop everything, for tests.
+func DiscardLogging() *Logging {
+	l, _ := NewLogging(LogConfig{}, io.Discard)
+	return l
+}
+
+// Logger returns the logger of a component. Its records carry a "component"
+// attribute and are filtered by the component's level.
+func (l *Logging) Logger(component string) *slog.Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	level, ok := l.levels[component]
+	if !ok {
+		level = new(slog.LevelVar)
+		level.Set(l.levelOf(component))
+		l.levels[component] = level
+	}
+	return slog.New(&levelHandler{
+		level:   level,
+		Handler: l.handler.WithAttrs([]slog.Attr{slog.String("component", component)}),
+	})
+}
+
+// SetLevels changes the default level and the component levels. Loggers that
+// were already handed out follow the change.
+func (l *Logging) SetLevels(level string, components map[string]string) error {
+	base, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+	parsed := make(map[string]slog.Level, len(components))
+	for component, level := range components {
+		if parsed[component], err = parseLogLevel(level); err != nil {
+			return fmt.Errorf("component %q: %w", component, err)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = base
+	l.components = parsed
+	for component, level := range l.levels {
+		level.Set(l.levelOf(component))
+	}
+	return nil
+}
+
+func (l *Logging) levelOf(component string) slog.Level {
+	if level, ok := l.components[component]; ok {
+		return level
+	}
+	return l.level
+}
+
+func parseLogLevel(s string) (slog.Level, error) {
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: expected \"debug\", \"info\", \"warn\" or \"error\"", s)
+	}
+	return level, nil
+}
+
+// levelHandler filters the records of a component by its level.
+type levelHandler struct {
+	level slog.Leveler
+	slog.Handler
+}
+
+func (h *levelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{level: h.level, Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{level: h.level, Handler: h.Handler.WithGroup(name)}
+}
+
+type loggerContextKey struct{}
+
+// ContextWithLogger returns a copy of ctx carrying the logger, typically one
+// tagged with the request ID.
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// LoggerFromContext returns the logger stored in ctx, or fallback if there is none.
+func LoggerFromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return fallback
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
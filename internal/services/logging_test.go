@@ -0,0 +1,80 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+func TestLogging_ComponentLevels(t *testing.T) {
+	var out bytes.Buffer
+	logging, err := services.NewLogging(services.LogConfig{
+		Level:      "warn",
+		Format:     services.LogFormatJSON,
+		Components: map[string]string{"http": "info"},
+	}, &out)
+	require.NoError(t, err)
+
+	logging.Logger("http").Info("request served")
+	logging.Logger("batch").Info("posted batch")
+	logging.Logger("batch").Warn("retrying batch", "attempt", 2)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var first, second map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+	assert.Equal(t, "http", first["component"])
+	assert.Equal(t, "request served", first["msg"])
+	assert.Equal(t, "batch", second["component"])
+	assert.Equal(t, "WARN", second["level"])
+	assert.Equal(t, float64(2), second["attempt"])
+}
+
+func TestLogging_SetLevelsUpdatesExistingLoggers(t *testing.T) {
+	var out bytes.Buffer
+	logging, err := services.NewLogging(services.LogConfig{Level: "info"}, &out)
+	require.NoError(t, err)
+	logger := logging.Logger("sink")
+
+	logger.Debug("hidden")
+	require.NoError(t, logging.SetLevels("info", map[string]string{"sink": "DEBUG"}))
+	logger.Debug("shown")
+
+	assert.NotContains(t, out.String(), "hidden")
+	assert.Contains(t, out.String(), "msg=shown component=sink")
+}
+
+func TestLogging_RejectsInvalidSettings(t *testing.T) {
+	_, err := services.NewLogging(services.LogConfig{Level: "loud"}, &bytes.Buffer{})
+	assert.Error(t, err)
+
+	_, err = services.NewLogging(services.LogConfig{Components: map[string]string{"http": "loud"}}, &bytes.Buffer{})
+	assert.ErrorContains(t, err, `component "http"`)
+
+	_, err = services.NewLogging(services.LogConfig{Format: "xml"}, &bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestLogSink_LogsRecordsOnlyAtDebug(t *testing.T) {
+	var out bytes.Buffer
+	logging, err := services.NewLogging(services.LogConfig{Level: "info"}, &out)
+	require.NoError(t, err)
+	batch := [][]byte{[]byte(`{"secret":"s3cr3t"}`)}
+
+	sink := services.NewLogSink(logging.Logger(services.LogComponentSink))
+	require.NoError(t, sink.Write(context.Background(), batch))
+	assert.Contains(t, out.String(), "records=1")
+	assert.NotContains(t, out.String(), "s3cr3t")
+
+	require.NoError(t, logging.SetLevels("debug", nil))
+	require.NoError(t, sink.Write(context.Background(), batch))
+	assert.Contains(t, out.String(), "s3cr3t")
+}
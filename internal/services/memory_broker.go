@@ -0,0 +1,360 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"play.ground/generic-data-collector/internal/interfaces"
+)
+
+const (
+	// OverflowBlock makes publishers wait for room in full subscription buffers.
+	OverflowBlock = "block"
+	// OverflowDropOldest drops the oldest buffered message to make room.
+	OverflowDropOldest = "drop_oldest"
+	// OverflowDropNewest drops the message being published.
+	OverflowDropNewest = "drop_newest"
+
+	// DefaultMemoryBufferSize is the number of messages buffered by each
+	// subscription by default.
+	DefaultMemoryBufferSize = 1024
+)
+
+// ErrBrokerClosed is returned when publishing to or subscribing on a closed broker.
+var ErrBrokerClosed = errors.New("broker closed")
+
+// MemoryBrokerConfig holds the settings of the MemoryBroker.
+type MemoryBrokerConfig struct {
+	// BufferSize is the number of messages buffered by each subscription;
+	// zero means DefaultMemoryBufferSize.
+	BufferSize int
+	// Overflow is what happens when a subscription buffer is full:
+	// OverflowBlock, OverflowDropOldest or OverflowDropNewest. Empty means
+	// OverflowBlock.
+	Overflow string
+}
+
+// Validate reports an unknown overflow policy or a negative buffer size.
+func (c MemoryBrokerConfig) Validate() error {
+	var errs []error
+	if c.BufferSize < 0 {
+		errs = append(errs, fmt.Errorf("buffer size %d is negative", c.BufferSize))
+	}
+	switch c.Overflow {
+	case "", OverflowBlock, OverflowDropOldest, OverflowDropNewest:
+	default:
+		errs = append(errs, fmt.Errorf("unknown overflow policy %q: expected %q, %q or %q",
+			c.Overflow, OverflowBlock, OverflowDropOldest, OverflowDropNewest))
+	}
+	return errors.Join(errs...)
+}
+
+// MemoryBroker is an in-process broker implementing both the Producer and the
+// Consumer interfaces: messages published to it are delivered to its own
+// subscriptions. Subjects and wildcards follow the NATS syntax, and queue
+// groups share their messages like in NATS. Every subscription has a bounded
+// buffer, and the overflow policy decides what happens when it is full.
+// Nothing is persisted and acknowledgements are no-ops, so it suits tests and
+// single-process deployments only.
+type MemoryBroker struct {
+	cfg     MemoryBrokerConfig
+	dropped atomic.Uint64
+
+	mu     sync.RWMutex
+	subs   []*memorySubscription
+	next   map[string]int // next member of each queue group to receive a message
+	closed bool
+}
+
+type memorySubscription struct {
+	pattern string
+	queue   string
+	ch      chan interfaces.Message
+	done    chan struct{}
+
+	// mu is held for reading while delivering, so the channel is only closed
+	// once no publisher is sending to it.
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewMemoryBroker creates an empty MemoryBroker.
+func NewMemoryBroker(cfg MemoryBrokerConfig) (*MemoryBroker, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.BufferSize == 0 {
+		cfg.BufferSize = DefaultMemoryBufferSize
+	}
+	if cfg.Overflow == "" {
+		cfg.Overflow = OverflowBlock
+	}
+	return &MemoryBroker{cfg: cfg, next: make(map[string]int)}, nil
+}
+
+// Publish delivers the message to every matching subscription, and to one
+// member of every matching queue group. When the buffer of a receiving
+// subscription is full, it blocks or drops a message according to the
+// overflow policy.
+func (b *MemoryBroker) Publish(topic string, message []byte, header interfaces.Header) error {
+	return b.PublishSync(context.Background(), topic, message, header)
+}
+
+// PublishSync is like Publish, but gives up once the context is done.
+func (b *MemoryBroker) PublishSync(ctx context.Context, topic string, message []byte, header interfaces.Header) error {
+	if err := ValidateSubject(topic, false); err != nil {
+		return err
+	}
+	targets, err := b.route(topic)
+	if err != nil {
+		return err
+	}
+
+	msg := &memoryMessage{subject: topic, data: append([]byte(nil), message...), header: copyHeader(header)}
+	for _, sub := range targets {
+		dropped, err := sub.deliver(ctx, msg, b.cfg.Overflow)
+		b.dropped.Add(dropped)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// route returns the subscriptions receiving a message published to subject.
+func (b *MemoryBroker) route(subject string) ([]*memorySubscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrBrokerClosed
+	}
+	var targets []*memorySubscription
+	groups := make(map[string][]*memorySubscription)
+	for _, sub := range b.subs {
+		if !MatchSubject(sub.pattern, subject) {
+			continue
+		}
+		if sub.queue == "" {
+			targets = append(targets, sub)
+		} else {
+			groups[sub.queue] = append(groups[sub.queue], sub)
+		}
+	}
+	for queue, members := range groups {
+		i := b.next[queue] % len(members)
+		b.next[queue] = i + 1
+		targets = append(targets, members[i])
+	}
+	return targets, nil
+}
+
+// Subscribe returns a channel receiving the messages published to subjects
+// matching topic, which may contain wildcards.
+func (b *MemoryBroker) Subscribe(topic string) (<-chan interfaces.Message, error) {
+	return b.subscribe(topic, "")
+}
+
+// QueueSubscribe is like Subscribe, but each message is delivered to only one
+// member of the queue group.
+func (b *MemoryBroker) QueueSubscribe(topic, queue string) (<-chan interfaces.Message, error) {
+	return b.subscribe(topic, queue)
+}
+
+func (b *MemoryBroker) subscribe(topic, queue string) (<-chan interfaces.Message, error) {
+	if err := ValidateSubject(topic, true); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrBrokerClosed
+	}
+	sub := &memorySubscription{
+		pattern: topic,
+		queue:   queue,
+		ch:      make(chan interfaces.Message, b.cfg.BufferSize),
+		done:    make(chan struct{}),
+	}
+	b.subs = append(b.subs, sub)
+	return sub.ch, nil
+}
+
+// Unsubscribe closes the channels of the subscriptions to topic; messages
+// still buffered in them can be read until the channels are drained.
+func (b *MemoryBroker) Unsubscribe(topic string) error {
+	b.mu.Lock()
+	var dropped []*memorySubscription
+	kept := b.subs[:0]
+	for _, sub := range b.subs {
+		if sub.pattern == topic {
+			dropped = append(dropped, sub)
+		} else {
+			kept = append(kept, sub)
+		}
+	}
+	b.subs = kept
+	b.mu.Unlock()
+
+	for _, sub := range dropped {
+		sub.close()
+	}
+	return nil
+}
+
+// Pending returns the number of messages buffered in the subscription channels.
+func (b *MemoryBroker) Pending() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	pending := 0
+	for _, sub := range b.subs {
+		pending += len(sub.ch)
+	}
+	return pending
+}
+
+// Dropped returns the number of messages dropped because a subscription
+// buffer was full.
+func (b *MemoryBroker) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Health reports an error once the broker is closed.
+func (b *MemoryBroker) Health(context.Context) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return ErrBrokerClosed
+	}
+	return nil
+}
+
+// Close closes every subscription channel; messages still buffered in them
+// can be read until the channels are drained. Publishing afterwards fails.
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = nil
+	b.closed = true
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.close()
+	}
+	return nil
+}
+
+// deliver buffers the message, applying the overflow policy if the buffer is
+// full. It returns the number of messages dropped.
+func (s *memorySubscription) deliver(ctx context.Context, msg interfaces.Message, overflow string) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return 0, nil
+	}
+
+	switch overflow {
+	case OverflowDropNewest:
+		select {
+		case s.ch <- msg:
+			return 0, nil
+		default:
+			return 1, nil
+		}
+	case OverflowDropOldest:
+		// Other publishers may fill the room made for the message, so this
+		// may take several attempts.
+		var dropped uint64
+		for {
+			select {
+			case s.ch <- msg:
+				return dropped, nil
+			default:
+			}
+			select {
+			case <-s.ch:
+				dropped++
+			default:
+			}
+		}
+	}
+
+	select {
+	case s.ch <- msg:
+		return 0, nil
+	case <-s.done:
+		return 0, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (s *memorySubscription) close() {
+	// Wake up blocked publishers before waiting for them.
+	close(s.done)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	close(s.ch)
+}
+
+// MatchSubject reports whether subject matches pattern in the NATS syntax: "*"
+// matches a single token and a final ">" matches one or more tokens.
+func MatchSubject(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		if token == ">" && i == len(patternTokens)-1 {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) || (token != "*" && token != subjectTokens[i]) {
+			return false
+		}
+	}
+	return len(patternTokens) == len(subjectTokens)
+}
+
+func copyHeader(header interfaces.Header) interfaces.Header {
+	if len(header) == 0 {
+		return nil
+	}
+	copied := make(interfaces.Header, len(header))
+	for key, value := range header {
+		copied[key] = value
+	}
+	return copied
+}
+
+// memoryMessage is a message delivered by the MemoryBroker. Messages are
+// delivered at most once, so the acknowledgement methods are no-ops.
+type memoryMessage struct {
+	subject string
+	data    []byte
+	header  interfaces.Header
+}
+
+func (m *memoryMessage) Data() []byte { return m.data }
+
+func (m *memoryMessage) Subject() string { return m.subject }
+
+func (m *memoryMessage) Header() interfaces.Header { return m.header }
+
+func (m *memoryMessage) Ack() error { return nil }
+
+func (m *memoryMessage) Nak(time.Duration) error { return nil }
+
+func (m *memoryMessage) Term() error { return nil }
+
+func (m *memoryMessage) InProgress() error { return nil }
+
+func (m *memoryMessage) NumDelivered() uint64 { return 1 }
@@ -0,0 +1,173 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"play.ground/generic-data-collector/internal/interfaces"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+func receive(t *testing.T, ch <-chan interfaces.Message) interfaces.Message {
+	t.Helper()
+	select {
+	case msg, ok := <-ch:
+		require.True(t, ok, "channel closed")
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+func newMemoryBroker(t *testing.T, cfg services.MemoryBrokerConfig) *services.MemoryBroker {
+	t.Helper()
+	broker, err := services.NewMemoryBroker(cfg)
+	require.NoError(t, err)
+	return broker
+}
+
+func TestMatchSubject(t *testing.T) {
+	tests := []struct {
+		pattern, subject string
+		match            bool
+	}{
+		{"metrics", "metrics", true},
+		{"metrics", "metrics.cpu", false},
+		{"ingest.*", "ingest.events", true},
+		{"ingest.*", "ingest.events.raw", false},
+		{"ingest.*", "ingest", false},
+		{"ingest.>", "ingest.events.raw", true},
+		{"ingest.>", "ingest", false},
+		{"*.events", "ingest.events", true},
+		{">", "metrics", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.match, services.MatchSubject(tt.pattern, tt.subject), "%s matching %s", tt.pattern, tt.subject)
+	}
+}
+
+func TestMemoryBroker_PublishSubscribe(t *testing.T) {
+	broker := newMemoryBroker(t, services.MemoryBrokerConfig{})
+	all, err := broker.Subscribe("ingest.>")
+	require.NoError(t, err)
+	events, err := broker.Subscribe("ingest.events")
+	require.NoError(t, err)
+
+	require.NoError(t, broker.Publish("ingest.events", []byte(`{"a":1}`), interfaces.Header{"Client-Id": "c1"}))
+	require.NoError(t, broker.PublishSync(context.Background(), "ingest.logs", []byte(`{"b":2}`), nil))
+
+	msg := receive(t, all)
+	assert.Equal(t, "ingest.events", msg.Subject())
+	assert.Equal(t, `{"a":1}`, string(msg.Data()))
+	assert.Equal(t, "c1", msg.Header()["Client-Id"])
+	assert.Equal(t, "ingest.logs", receive(t, all).Subject())
+	assert.Equal(t, "ingest.events", receive(t, events).Subject())
+	assert.Equal(t, 0, broker.Pending())
+
+	require.Error(t, broker.Publish("ingest.*", nil, nil))
+}
+
+func TestMemoryBroker_QueueGroups(t *testing.T) {
+	broker := newMemoryBroker(t, services.MemoryBrokerConfig{})
+	first, err := broker.QueueSubscribe("metrics", "workers")
+	require.NoError(t, err)
+	second, err := broker.QueueSubscribe("metrics", "workers")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, broker.Publish("metrics", []byte("{}"), nil))
+	}
+	// Each message goes to a single member of the group.
+	assert.Equal(t, 5, len(first))
+	assert.Equal(t, 5, len(second))
+}
+
+func TestMemoryBroker_Close(t *testing.T) {
+	broker := newMemoryBroker(t, services.MemoryBrokerConfig{})
+	ch, err := broker.Subscribe("metrics")
+	require.NoError(t, err)
+	require.NoError(t, broker.Publish("metrics", []byte("{}"), nil))
+
+	require.NoError(t, broker.Close())
+	// Buffered messages can still be read before the channel is closed.
+	receive(t, ch)
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	assert.ErrorIs(t, broker.Publish("metrics", []byte("{}"), nil), services.ErrBrokerClosed)
+	assert.ErrorIs(t, broker.Health(context.Background()), services.ErrBrokerClosed)
+}
+
+func TestMemoryBroker_Unsubscribe(t *testing.T) {
+	broker := newMemoryBroker(t, services.MemoryBrokerConfig{})
+	defer broker.Close()
+	metrics, err := broker.Subscribe("metrics")
+	require.NoError(t, err)
+	events, err := broker.Subscribe("events")
+	require.NoError(t, err)
+	require.NoError(t, broker.Publish("metrics", []byte("{}"), nil))
+
+	require.NoError(t, broker.Unsubscribe("metrics"))
+	receive(t, metrics)
+	_, ok := <-metrics
+	assert.False(t, ok)
+
+	// Other subscriptions are left alone.
+	require.NoError(t, broker.Publish("metrics", []byte("{}"), nil))
+	require.NoError(t, broker.Publish("events", []byte("{}"), nil))
+	assert.Equal(t, "events", receive(t, events).Subject())
+}
+
+func TestMemoryBroker_Overflow(t *testing.T) {
+	publish := func(broker *services.MemoryBroker, values ...string) {
+		for _, value := range values {
+			require.NoError(t, broker.Publish("metrics", []byte(value), nil))
+		}
+	}
+
+	t.Run("drop oldest", func(t *testing.T) {
+		broker := newMemoryBroker(t, services.MemoryBrokerConfig{BufferSize: 2, Overflow: services.OverflowDropOldest})
+		ch, err := broker.Subscribe("metrics")
+		require.NoError(t, err)
+		publish(broker, "1", "2", "3")
+		assert.Equal(t, "2", string(receive(t, ch).Data()))
+		assert.Equal(t, "3", string(receive(t, ch).Data()))
+		assert.Equal(t, uint64(1), broker.Dropped())
+	})
+
+	t.Run("drop newest", func(t *testing.T) {
+		broker := newMemoryBroker(t, services.MemoryBrokerConfig{BufferSize: 2, Overflow: services.OverflowDropNewest})
+		ch, err := broker.Subscribe("metrics")
+		require.NoError(t, err)
+		publish(broker, "1", "2", "3")
+		assert.Equal(t, "1", string(receive(t, ch).Data()))
+		assert.Equal(t, "2", string(receive(t, ch).Data()))
+		assert.Equal(t, uint64(1), broker.Dropped())
+	})
+
+	t.Run("block", func(t *testing.T) {
+		broker := newMemoryBroker(t, services.MemoryBrokerConfig{BufferSize: 1})
+		ch, err := broker.Subscribe("metrics")
+		require.NoError(t, err)
+		publish(broker, "1")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, broker.PublishSync(ctx, "metrics", []byte("2"), nil), context.DeadlineExceeded)
+
+		// Reading makes room for blocked publishers.
+		done := make(chan error, 1)
+		go func() { done <- broker.Publish("metrics", []byte("3"), nil) }()
+		assert.Equal(t, "1", string(receive(t, ch).Data()))
+		require.NoError(t, <-done)
+		assert.Equal(t, "3", string(receive(t, ch).Data()))
+		assert.Zero(t, broker.Dropped())
+	})
+
+	_, err := services.NewMemoryBroker(services.MemoryBrokerConfig{Overflow: "spill"})
+	assert.Error(t, err)
+}
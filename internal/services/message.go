@@ -1,6 +1,8 @@
 package services
 
 import (
+	"time"
+
 	"play.ground/generic-data-collector/internal/interfaces"
 )
 
@@ -13,6 +15,20 @@ func (msg NonAckPubSubMessage) Data() []byte {
 	return msg.data
 }
 
+func (msg NonAckPubSubMessage) Subject() string { return "" }
+
+func (msg NonAckPubSubMessage) Header() interfaces.Header { return nil }
+
+func (msg NonAckPubSubMessage) Ack() error { return nil }
+
+func (msg NonAckPubSubMessage) Nak(time.Duration) error { return nil }
+
+func (msg NonAckPubSubMessage) Term() error { return nil }
+
+func (msg NonAckPubSubMessage) InProgress() error { return nil }
+
+func (msg NonAckPubSubMessage) NumDelivered() uint64 { return 1 }
+
 func NewNonAckPubSubMessage(data []byte) interfaces.Message {
 	return NonAckPubSubMessage{data: data}
 }
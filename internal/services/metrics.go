@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"play.ground/generic-data-collector/internal/interfaces"
+)
+
+const metricsNamespace = "ingest"
+
+// Metrics holds the Prometheus collectors of a process. Every process has its
+// own registry, so tests can create as many as they need.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests    *prometheus.CounterVec
+	httpDuration    *prometheus.HistogramVec
+	publishes       *prometheus.CounterVec
+	publishDuration *prometheus.HistogramVec
+	batchSize       prometheus.Histogram
+	flushDuration   *prometheus.HistogramVec
+	configReloads   *prometheus.CounterVec
+	restartPending  prometheus.Gauge
+}
+
+// NewMetrics creates the collectors and registers them, along with the Go
+// runtime and process collectors, on a new registry.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		publishes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "publishes_total",
+			Help:      "Messages published to the broker by subject and result.",
+		}, []string{"subject", "result"}),
+		publishDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "publish_duration_seconds",
+			Help:      "Time taken to publish a message, by subject.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"subject"}),
+		batchSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "batch_size_records",
+			Help:      "Number of records in flushed batches.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 9),
+		}),
+		flushDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "flush_duration_seconds",
+			Help:      "Time taken to write a batch to the sink, including retries, by result.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+		}, []string{"result"}),
+		configReloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "config_reloads_total",
+			Help:      "Config reloads by result.",
+		}, []string{"result"}),
+		restartPending: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "config_restart_pending",
+			Help:      "1 if the config has changed settings that only apply after a restart, 0 otherwise.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.publishes,
+		m.publishDuration,
+		m.batchSize,
+		m.flushDuration,
+		m.configReloads,
+		m.restartPending,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveHTTPRequest records a served HTTP request. route is the route template,
+// not the request path, to keep the number of series bounded.
+func (m *Metrics) ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+func (m *Metrics) observePublish(subject string, duration time.Duration, err error) {
+	m.publishes.WithLabelValues(subject, resultLabel(err)).Inc()
+	m.publishDuration.WithLabelValues(subject).Observe(duration.Seconds())
+}
+
+func (m *Metrics) observeFlush(size int, duration time.Duration, err error) {
+	m.batchSize.Observe(float64(size))
+	m.flushDuration.WithLabelValues(resultLabel(err)).Observe(duration.Seconds())
+}
+
+// ObserveConfigReload records a config reload.
+func (m *Metrics) ObserveConfigReload(err error) {
+	m.configReloads.WithLabelValues(resultLabel(err)).Inc()
+}
+
+// SetRestartPending reports whether the config has changed settings that only
+// apply after a restart.
+func (m *Metrics) SetRestartPending(pending bool) {
+	if pending {
+		m.restartPending.Set(1)
+	} else {
+		m.restartPending.Set(0)
+	}
+}
+
+func resultLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// registerBatchStats exposes the BatchProcessor counters. They are read from
+// the stats when scraped, so the counters have a single source of truth.
+func (m *Metrics) registerBatchStats(stats *BatchStats) {
+	counter := func(name, help string, value func(BatchStatsSnapshot) uint64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "batch",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return float64(value(stats.snapshot())) })
+	}
+
+	m.registry.MustRegister(
+		counter("received_total", "Messages taken from the consumer.",
+			func(s BatchStatsSnapshot) uint64 { return s.Received }),
+		counter("batched_total", "Messages handed to the sink in a flush.",
+			func(s BatchStatsSnapshot) uint64 { return s.Batched }),
+		counter("written_total", "Messages successfully written to the sink.",
+			func(s BatchStatsSnapshot) uint64 { return s.Written }),
+		counter("failed_total", "Messages whose batch could not be written.",
+			func(s BatchStatsSnapshot) uint64 { return s.Failed }),
+		counter("retries_total", "Sink write attempts beyond the first.",
+			func(s BatchStatsSnapshot) uint64 { return s.Retries }),
+		counter("backpressure_total", "Times consumption paused because all flush slots were busy.",
+			func(s BatchStatsSnapshot) uint64 { return s.Backpressure }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "batch",
+			Name:      "in_flight",
+			Help:      "Batches being written or waiting for a flush worker.",
+		}, func() float64 { return float64(stats.snapshot().InFlight) }),
+	)
+
+	for _, reason := range []FlushReason{FlushMaxRecords, FlushMaxBytes, FlushMaxAge, FlushShutdown, FlushDrained} {
+		reason := reason
+		m.registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Subsystem:   "batch",
+			Name:        "flushes_total",
+			Help:        "Batch flushes by the reason that triggered them.",
+			ConstLabels: prometheus.Labels{"reason": string(reason)},
+		}, func() float64 { return float64(stats.snapshot().Flushes[reason]) }))
+	}
+}
+
+// pendingReporter is implemented by consumers that buffer messages in channels.
+type pendingReporter interface {
+	// Pending returns the number of messages buffered in the subscription
+	// channels and not yet read.
+	Pending() int
+}
+
+// droppedReporter is implemented by consumers that drop messages when their
+// buffers overflow.
+type droppedReporter interface {
+	// Dropped returns the number of messages dropped so far.
+	Dropped() uint64
+}
+
+// RegisterConsumer exposes the depth of the consumer's subscription channels
+// and the number of messages it dropped, if the consumer reports them.
+func (m *Metrics) RegisterConsumer(consumer interfaces.Consumer) {
+	if reporter, ok := consumer.(pendingReporter); ok {
+		m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "consumer_pending_messages",
+			Help:      "Messages buffered in the consumer's subscription channels.",
+		}, func() float64 { return float64(reporter.Pending()) }))
+	}
+	if reporter, ok := consumer.(droppedReporter); ok {
+		m.registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "consumer_dropped_messages_total",
+			Help:      "Messages dropped because a subscription buffer was full.",
+		}, func() float64 { return float64(reporter.Dropped()) }))
+	}
+}
+
+// InstrumentProducer wraps the producer to count and time its publishes.
+func (m *Metrics) InstrumentProducer(producer interfaces.Producer) interfaces.Producer {
+	return &instrumentedProducer{Producer: producer, metrics: m}
+}
+
+type instrumentedProducer struct {
+	interfaces.Producer
+	metrics *Metrics
+}
+
+func (p *instrumentedProducer) Publish(topic string, message []byte, header interfaces.Header) error {
+	start := time.Now()
+	err := p.Producer.Publish(topic, message, header)
+	p.metrics.observePublish(topic, time.Since(start), err)
+	return err
+}
+
+func (p *instrumentedProducer) PublishSync(ctx context.Context, topic string, message []byte, header interfaces.Header) error {
+	start := time.Now()
+	err := p.Producer.PublishSync(ctx, topic, message, header)
+	p.metrics.observePublish(topic, time.Since(start), err)
+	return err
+}
@@ -0,0 +1,76 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+func scrape(t *testing.T, metrics *services.Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestMetrics_BatchProcessor(t *testing.T) {
+	metrics := services.NewMetrics()
+	mockConsumer := services.NewMockConsumer()
+	mockSink := services.NewMockSink()
+	processor := services.NewBatchProcessor(mockConsumer, mockSink,
+		services.WithBatchLimits(services.BatchLimits{MaxRecords: 5}),
+		services.WithMetrics(metrics),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- processor.Start(ctx, "metrics")
+	}()
+
+	messages := make([]*services.MockMessage, 5)
+	for i := range messages {
+		messages[i] = services.NewMockMessage([]byte(fmt.Sprintf(`{"value": %d}`, i)))
+		mockConsumer.SendMessage(messages[i])
+	}
+	require.Eventually(t, messages[4].Acked, time.Second, 5*time.Millisecond)
+
+	body := scrape(t, metrics)
+	assert.Contains(t, body, "ingest_batch_received_total 5")
+	assert.Contains(t, body, "ingest_batch_written_total 5")
+	assert.Contains(t, body, `ingest_batch_flushes_total{reason="max_records"} 1`)
+	assert.Contains(t, body, "ingest_batch_size_records_count 1")
+	assert.Contains(t, body, "ingest_batch_size_records_sum 5")
+	assert.Contains(t, body, `ingest_flush_duration_seconds_count{result="ok"} 1`)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestMetrics_InstrumentProducer(t *testing.T) {
+	metrics := services.NewMetrics()
+	mockProducer := services.NewMockProducer()
+	producer := metrics.InstrumentProducer(mockProducer)
+
+	require.NoError(t, producer.Publish("metrics", []byte(`{}`), nil))
+	require.NoError(t, producer.PublishSync(context.Background(), "metrics", []byte(`{}`), nil))
+	mockProducer.PublishErr = errors.New("nats: connection closed")
+	require.Error(t, producer.Publish("metrics", []byte(`{}`), nil))
+
+	body := scrape(t, metrics)
+	assert.Contains(t, body, `ingest_publishes_total{result="ok",subject="metrics"} 2`)
+	assert.Contains(t, body, `ingest_publishes_total{result="error",subject="metrics"} 1`)
+	assert.Contains(t, body, `ingest_publish_duration_seconds_count{subject="metrics"} 3`)
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"log"
 
 	"play.ground/generic-data-collector/internal/interfaces"
@@ -10,6 +11,8 @@ import (
 type MockConsumer struct {
 	messages chan interfaces.Message
 	done     chan struct{}
+	// HealthErr is returned by Health.
+	HealthErr error
 }
 
 // NewMockConsumer creates a new MockConsumer.
@@ -27,11 +30,30 @@ func (m *MockConsumer) Subscribe(topic string) (<-chan interfaces.Message, error
 	return m.messages, nil
 }
 
+// QueueSubscribe simulates subscribing to a topic as a member of a queue group.
+// It returns the same channel as Subscribe.
+func (m *MockConsumer) QueueSubscribe(topic, queue string) (<-chan interfaces.Message, error) {
+	log.Printf("MOCK CONSUMER: Subscribing to topic '%s' in queue group '%s'\n", topic, queue)
+	return m.messages, nil
+}
+
+// Unsubscribe simulates unsubscribing from a topic. The channel is shared by
+// all subscriptions, so it stays open.
+func (m *MockConsumer) Unsubscribe(topic string) error {
+	log.Printf("MOCK CONSUMER: Unsubscribing from topic '%s'\n", topic)
+	return nil
+}
+
 // SendMessage allows tests to manually inject a message into the consumer's channel.
 func (m *MockConsumer) SendMessage(message interfaces.Message) {
 	m.messages <- message
 }
 
+// Health returns HealthErr.
+func (m *MockConsumer) Health(context.Context) error {
+	return m.HealthErr
+}
+
 // Close simulates closing the consumer.
 func (m *MockConsumer) Close() error {
 	log.Println("MOCK CONSUMER: Closed.")
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"play.ground/generic-data-collector/internal/interfaces"
+)
+
+// MockMessage is a mock implementation of the Message interface that records
+// how it was acknowledged.
+type MockMessage struct {
+	data      []byte
+	header    interfaces.Header
+	delivered uint64
+
+	mu         sync.Mutex
+	acked      bool
+	naked      bool
+	terminated bool
+	nakDelay   time.Duration
+	inProgress int
+}
+
+// NewMockMessage creates a MockMessage delivered for the first time.
+func NewMockMessage(data []byte) *MockMessage {
+	return &MockMessage{data: data, delivered: 1}
+}
+
+// NewMockMessageWithHeader creates a MockMessage carrying the given header.
+func NewMockMessageWithHeader(data []byte, header interfaces.Header) *MockMessage {
+	return &MockMessage{data: data, header: header, delivered: 1}
+}
+
+func (m *MockMessage) Data() []byte {
+	return m.data
+}
+
+func (m *MockMessage) Header() interfaces.Header {
+	return m.header
+}
+
+func (m *MockMessage) Subject() string {
+	return ""
+}
+
+func (m *MockMessage) Ack() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.acked = true
+	return nil
+}
+
+func (m *MockMessage) Nak(delay time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.naked = true
+	return nil
+}
+
+func (m *MockMessage) Term() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.terminated = true
+	return nil
+}
+
+func (m *MockMessage) InProgress() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inProgress++
+	return nil
+}
+
+func (m *MockMessage) NumDelivered() uint64 {
+	return m.delivered
+}
+
+// Acked reports whether Ack has been called.
+func (m *MockMessage) Acked() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.acked
+}
+
+// Naked reports whether Nak has been called.
+func (m *MockMessage) Naked() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.naked
+}
+
+// Terminated reports whether Term has been called.
+func (m *MockMessage) Terminated() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.terminated
+}
+
+// InProgressCount returns how many times InProgress has been called.
+func (m *MockMessage) InProgressCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inProgress
+}
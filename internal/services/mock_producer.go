@@ -1,15 +1,34 @@
 package services
 
 import (
+	"context"
 	"log"
 	"sync"
+	"time"
+
+	"play.ground/generic-data-collector/internal/interfaces"
 )
 
+// PublishedMessage is a message captured by the MockProducer.
+type PublishedMessage struct {
+	Topic  string
+	Data   []byte
+	Header interfaces.Header
+}
+
 // MockProducer is a mock implementation of the Producer interface.
 type MockProducer struct {
 	PublishedData    []byte
 	PublishedChannel string
-	mu               sync.Mutex
+	// Messages holds every message published so far, in order.
+	Messages []PublishedMessage
+	// PublishErr, when set, is returned by Publish instead of recording the message.
+	PublishErr error
+	// SyncDelay simulates the time the broker takes to confirm a PublishSync.
+	SyncDelay time.Duration
+	// HealthErr is returned by Health.
+	HealthErr error
+	mu        sync.Mutex
 }
 
 // NewMockProducer creates a new MockProducer.
@@ -18,17 +37,46 @@ func NewMockProducer() *MockProducer {
 }
 
 // Publish simulates publishing a message by printing it to the console and storing the data.
-func (m *MockProducer) Publish(topic string, message []byte) error {
+func (m *MockProducer) Publish(topic string, message []byte, header interfaces.Header) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if m.PublishErr != nil {
+		return m.PublishErr
+	}
+
 	m.PublishedChannel = topic
 	m.PublishedData = message
+	m.Messages = append(m.Messages, PublishedMessage{Topic: topic, Data: message, Header: header})
 
 	log.Printf("MOCK PRODUCER: Publishing to topic '%s': %s\n", topic, string(message))
 	return nil
 }
 
+// PublishSync behaves like Publish but waits SyncDelay for the simulated
+// confirmation, failing if the context is done first.
+func (m *MockProducer) PublishSync(ctx context.Context, topic string, message []byte, header interfaces.Header) error {
+	select {
+	case <-time.After(m.SyncDelay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return m.Publish(topic, message, header)
+}
+
+// Published returns a copy of all messages published so far.
+func (m *MockProducer) Published() []PublishedMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]PublishedMessage(nil), m.Messages...)
+}
+
+// Health returns HealthErr.
+func (m *MockProducer) Health(context.Context) error {
+	return m.HealthErr
+}
+
 // Close simulates closing the producer.
 func (m *MockProducer) Close() error {
 	log.Println("MOCK PRODUCER: Closed.")
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// MockSink is a mock implementation of the Sink interface that records every batch.
+type MockSink struct {
+	// WriteErr, when set, is returned by Write instead of recording the batch.
+	WriteErr error
+	// FailWrites limits WriteErr to the first FailWrites calls; zero means every call fails.
+	FailWrites int
+	// HealthErr is returned by Health.
+	HealthErr error
+	// Block, when set, makes Write wait until the channel is closed.
+	Block chan struct{}
+
+	batches [][][]byte
+	writes  int
+	mu      sync.Mutex
+}
+
+// NewMockSink creates a new MockSink.
+func NewMockSink() *MockSink {
+	return &MockSink{}
+}
+
+// Write records the batch.
+func (s *MockSink) Write(_ context.Context, batch [][]byte) error {
+	if s.Block != nil {
+		<-s.Block
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writes++
+	if s.WriteErr != nil && (s.FailWrites == 0 || s.writes <= s.FailWrites) {
+		return s.WriteErr
+	}
+	s.batches = append(s.batches, append([][]byte(nil), batch...))
+	return nil
+}
+
+// Health returns HealthErr.
+func (s *MockSink) Health(context.Context) error {
+	return s.HealthErr
+}
+
+// Close does nothing.
+func (s *MockSink) Close() error {
+	return nil
+}
+
+// Batches returns a copy of all batches written so far.
+func (s *MockSink) Batches() [][][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([][][]byte(nil), s.batches...)
+}
+
+// Writes returns the number of times Write has been called.
+func (s *MockSink) Writes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.writes
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"sync"
 
 	"play.ground/generic-data-collector/internal/interfaces"
@@ -11,10 +13,20 @@ import (
 // NATSConsumer implements the Consumer interface for receiving messages from NATS.
 type NATSConsumer struct {
 	conn *nats.Conn
-	subs []*nats.Subscription
+	subs []*natsSubscription
 	mu   sync.Mutex
 }
 
+// natsSubscription forwards the messages of a channel subscription to a Go
+// channel. The NATS library never closes the channels of channel
+// subscriptions, so done tells the forwarding goroutine to stop.
+type natsSubscription struct {
+	sub       *nats.Subscription
+	natsMsgCh chan *nats.Msg
+	dataCh    chan interfaces.Message
+	done      chan struct{}
+}
+
 // NewNATSConsumer creates a new consumer that connects to the given NATS URL.
 func NewNATSConsumer(url string) (interfaces.Consumer, error) {
 	nc, err := nats.Connect(url)
@@ -27,40 +39,141 @@ func NewNATSConsumer(url string) (interfaces.Consumer, error) {
 // Subscribe starts listening to a given topic and returns a Go channel
 // from which message payloads can be read.
 func (c *NATSConsumer) Subscribe(topic string) (<-chan interfaces.Message, error) {
+	return c.subscribe(topic, "")
+}
+
+// QueueSubscribe starts listening to a given topic as a member of the queue
+// group, so that each message is delivered to only one member of the group.
+func (c *NATSConsumer) QueueSubscribe(topic, queue string) (<-chan interfaces.Message, error) {
+	return c.subscribe(topic, queue)
+}
+
+func (c *NATSConsumer) subscribe(topic, queue string) (<-chan interfaces.Message, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	// Channel for nats.Msg from the NATS library
 	natsMsgCh := make(chan *nats.Msg, 64)
 
-	sub, err := c.conn.ChanSubscribe(topic, natsMsgCh)
+	var sub *nats.Subscription
+	var err error
+	if queue == "" {
+		sub, err = c.conn.ChanSubscribe(topic, natsMsgCh)
+	} else {
+		sub, err = c.conn.ChanQueueSubscribe(topic, queue, natsMsgCh)
+	}
 	if err != nil {
 		return nil, err
 	}
-	c.subs = append(c.subs, sub)
 
 	// Channel for interfaces.Message to return to the caller
-	dataCh := make(chan interfaces.Message, 64)
+	s := &natsSubscription{
+		sub:       sub,
+		natsMsgCh: natsMsgCh,
+		dataCh:    make(chan interfaces.Message, 64),
+		done:      make(chan struct{}),
+	}
+	c.subs = append(c.subs, s)
+
+	go s.forward()
+
+	return s.dataCh, nil
+}
+
+// forward transfers message data from the nats.Msg channel to the data
+// channel until the subscription is closed, then hands over the messages still
+// buffered and closes the data channel.
+func (s *natsSubscription) forward() {
+	defer close(s.dataCh)
+	for {
+		select {
+		case msg := <-s.natsMsgCh:
+			select {
+			case s.dataCh <- NewNATSMessage(msg):
+			case <-s.done:
+				s.handOver(msg)
+				return
+			}
+		case <-s.done:
+			s.handOver(nil)
+			return
+		}
+	}
+}
+
+// handOver moves the given and the buffered messages to the data channel as
+// long as it has room. Nobody may be reading anymore, so it never blocks; core
+// NATS delivers at most once, and the messages that do not fit are dropped.
+func (s *natsSubscription) handOver(msg *nats.Msg) {
+	for {
+		if msg != nil {
+			select {
+			case s.dataCh <- NewNATSMessage(msg):
+			default:
+				return
+			}
+		}
+		select {
+		case msg = <-s.natsMsgCh:
+		default:
+			return
+		}
+	}
+}
+
+// close unsubscribes and stops the forwarding goroutine.
+func (s *natsSubscription) close() error {
+	err := s.sub.Unsubscribe()
+	close(s.done)
+	return err
+}
 
-	// Goroutine to transfer message data from nats.Msg channel to the data channel.
-	go func() {
-		defer close(dataCh)
-		for msg := range natsMsgCh {
-			dataCh <- NewNATSMessage(msg)
+// Unsubscribe stops the subscriptions to the topic and closes their channels.
+func (c *NATSConsumer) Unsubscribe(topic string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	kept := c.subs[:0]
+	for _, s := range c.subs {
+		if s.sub.Subject != topic {
+			kept = append(kept, s)
+			continue
+		}
+		if err := s.close(); err != nil {
+			errs = append(errs, err)
 		}
-	}()
+	}
+	c.subs = kept
+	return errors.Join(errs...)
+}
+
+// Pending returns the number of messages buffered in the subscription channels.
+func (c *NATSConsumer) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pending := 0
+	for _, s := range c.subs {
+		pending += len(s.natsMsgCh) + len(s.dataCh)
+	}
+	return pending
+}
 
-	return dataCh, nil
+// Health reports an error unless the consumer is connected to the server.
+func (c *NATSConsumer) Health(context.Context) error {
+	return connectionHealth(c.conn)
 }
 
-// Close unsubscribes from all topics and closes the NATS connection.
+// Close unsubscribes from all topics, closing their channels, and closes the
+// NATS connection.
 func (c *NATSConsumer) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	var firstErr error
-	for _, sub := range c.subs {
-		if err := sub.Unsubscribe(); err != nil && firstErr == nil {
+	for _, s := range c.subs {
+		if err := s.close(); err != nil && firstErr == nil {
 			firstErr = err
 		}
 	}
@@ -1,11 +1,15 @@
 package services
 
 import (
+	"time"
+
 	"github.com/nats-io/nats.go"
 	"play.ground/generic-data-collector/internal/interfaces"
 )
 
 type (
+	// NATSMessage wraps a core NATS message. Core NATS delivers at most once,
+	// so the acknowledgement methods are no-ops.
 	NATSMessage struct {
 		msg *nats.Msg // Embed or reference the underlying NATS message
 	}
@@ -15,8 +19,40 @@ func (m NATSMessage) Data() []byte {
 	return m.msg.Data // Direct delegation; customize if you need to include Subject/Header
 }
 
+func (m NATSMessage) Subject() string {
+	return m.msg.Subject
+}
+
+func (m NATSMessage) Header() interfaces.Header {
+	return fromNATSHeader(m.msg.Header)
+}
+
+func (m NATSMessage) Ack() error { return nil }
+
+func (m NATSMessage) Nak(time.Duration) error { return nil }
+
+func (m NATSMessage) Term() error { return nil }
+
+func (m NATSMessage) InProgress() error { return nil }
+
+func (m NATSMessage) NumDelivered() uint64 { return 1 }
+
 func NewNATSMessage(natsMsg *nats.Msg) interfaces.Message {
 	// Optional: Create a copy if you don't want to mutate the original nats.Msg
 	// cloned := *natsMsg // Shallow copy; deep copy Data if needed for safety
 	return NATSMessage{msg: natsMsg}
 }
+
+// fromNATSHeader keeps the first value of every NATS header.
+func fromNATSHeader(h nats.Header) interfaces.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	header := make(interfaces.Header, len(h))
+	for key, values := range h {
+		if len(values) > 0 {
+			header[key] = values[0]
+		}
+	}
+	return header
+}
@@ -1,6 +1,9 @@
 package services
 
 import (
+	"context"
+	"errors"
+
 	"play.ground/generic-data-collector/internal/interfaces"
 
 	"github.com/nats-io/nats.go"
@@ -8,24 +11,77 @@ import (
 
 // NATSProducer implements the Producer interface for sending messages to NATS.
 type NATSProducer struct {
-	conn *nats.Conn
+	conn   *nats.Conn
+	closed <-chan struct{}
 }
 
 // NewNATSProducer creates a new producer that connects to the given NATS URL.
 func NewNATSProducer(url string) (interfaces.Producer, error) {
-	nc, err := nats.Connect(url)
+	nc, closed, err := connectDrainable(url)
 	if err != nil {
 		return nil, err
 	}
-	return &NATSProducer{conn: nc}, nil
+	return &NATSProducer{conn: nc, closed: closed}, nil
 }
 
 // Publish sends a message to a specific topic in NATS.
-func (p *NATSProducer) Publish(topic string, message []byte) error {
-	return p.conn.Publish(topic, message)
+func (p *NATSProducer) Publish(topic string, message []byte, header interfaces.Header) error {
+	return p.conn.PublishMsg(newNATSMsg(topic, message, header))
+}
+
+// PublishSync sends a message and flushes the connection, so it returns only
+// after the server has processed the message or the context is done.
+func (p *NATSProducer) PublishSync(ctx context.Context, topic string, message []byte, header interfaces.Header) error {
+	if err := p.conn.PublishMsg(newNATSMsg(topic, message, header)); err != nil {
+		return err
+	}
+	return p.conn.FlushWithContext(ctx)
 }
 
-// Close drains and closes the NATS connection.
+// Health reports an error unless the producer is connected to the server.
+func (p *NATSProducer) Health(context.Context) error {
+	return connectionHealth(p.conn)
+}
+
+// Close drains and closes the NATS connection. It returns once every pending
+// message has been flushed to the server.
 func (p *NATSProducer) Close() error {
-	return p.conn.Drain()
+	return drain(p.conn, p.closed)
+}
+
+// connectDrainable connects to NATS and returns a channel that is closed once
+// the connection is closed, so that Close can wait for a drain to complete.
+func connectDrainable(url string) (*nats.Conn, <-chan struct{}, error) {
+	closed := make(chan struct{})
+	nc, err := nats.Connect(url, nats.ClosedHandler(func(*nats.Conn) { close(closed) }))
+	if err != nil {
+		return nil, nil, err
+	}
+	return nc, closed, nil
+}
+
+// drain flushes pending messages and waits for the connection to be closed.
+// Conn.Drain only starts draining, in the background.
+func drain(conn *nats.Conn, closed <-chan struct{}) error {
+	if err := conn.Drain(); err != nil {
+		if errors.Is(err, nats.ErrConnectionClosed) {
+			return nil
+		}
+		return err
+	}
+	<-closed
+	return nil
+}
+
+// newNATSMsg builds a NATS message, leaving out the header when it is empty so
+// the message can also be delivered by servers without header support.
+func newNATSMsg(subject string, data []byte, header interfaces.Header) *nats.Msg {
+	msg := &nats.Msg{Subject: subject, Data: data}
+	if len(header) > 0 {
+		msg.Header = make(nats.Header, len(header))
+		for key, value := range header {
+			msg.Header.Set(key, value)
+		}
+	}
+	return msg
 }
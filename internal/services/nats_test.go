@@ -0,0 +1,169 @@
+package services_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"play.ground/generic-data-collector/internal/interfaces"
+	"play.ground/generic-data-collector/internal/natstest"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+func TestNATS_PublishSubscribe(t *testing.T) {
+	url := natstest.Run(t)
+	producer, err := services.NewNATSProducer(url)
+	require.NoError(t, err)
+	defer producer.Close()
+	consumer, err := services.NewNATSConsumer(url)
+	require.NoError(t, err)
+	defer consumer.Close()
+
+	ch, err := consumer.Subscribe("ingest.>")
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, producer.PublishSync(ctx, "ingest.events", []byte(`{"a":1}`), interfaces.Header{"Client-Id": "c1"}))
+
+	msg := receive(t, ch)
+	assert.Equal(t, "ingest.events", msg.Subject())
+	assert.Equal(t, `{"a":1}`, string(msg.Data()))
+	assert.Equal(t, "c1", msg.Header()["Client-Id"])
+	assert.NoError(t, producer.Health(ctx))
+	assert.NoError(t, consumer.Health(ctx))
+}
+
+func TestNATS_Unsubscribe(t *testing.T) {
+	url := natstest.Run(t)
+	producer, err := services.NewNATSProducer(url)
+	require.NoError(t, err)
+	defer producer.Close()
+	consumer, err := services.NewNATSConsumer(url)
+	require.NoError(t, err)
+	defer consumer.Close()
+
+	ch, err := consumer.Subscribe("metrics")
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, producer.PublishSync(ctx, "metrics", []byte(`{}`), nil))
+	require.Eventually(t, func() bool { return consumer.(*services.NATSConsumer).Pending() == 1 }, 2*time.Second, 5*time.Millisecond)
+
+	// The buffered message is still delivered before the channel is closed.
+	require.NoError(t, consumer.Unsubscribe("metrics"))
+	receive(t, ch)
+	_, ok := <-ch
+	assert.False(t, ok)
+}
+
+func TestNATS_UnsubscribeWithFullBuffer(t *testing.T) {
+	url := natstest.Run(t)
+	producer, err := services.NewNATSProducer(url)
+	require.NoError(t, err)
+	defer producer.Close()
+	consumer, err := services.NewNATSConsumer(url)
+	require.NoError(t, err)
+	defer consumer.Close()
+
+	ch, err := consumer.Subscribe("metrics")
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 200; i++ {
+		require.NoError(t, producer.PublishSync(ctx, "metrics", []byte(`{}`), nil))
+	}
+	// Nobody reads, so the forwarder blocks on the full channel.
+	require.Eventually(t, func() bool { return consumer.(*services.NATSConsumer).Pending() >= 128 }, 2*time.Second, 5*time.Millisecond)
+
+	require.NoError(t, consumer.Unsubscribe("metrics"))
+	time.Sleep(50 * time.Millisecond)
+
+	// The forwarder has given up on what did not fit and closed the channel.
+	received := 0
+	for range ch {
+		received++
+	}
+	assert.Less(t, received, 128)
+}
+
+func TestJetStream_RedeliversNakedMessages(t *testing.T) {
+	url := natstest.Run(t)
+	cfg := services.JetStreamConfig{
+		Stream:     "INGEST",
+		Subjects:   []string{"metrics"},
+		AckTimeout: 5 * time.Second,
+		Durable:    "workers",
+		AckWait:    time.Minute,
+	}
+	producer, err := services.NewJetStreamProducer(url, cfg)
+	require.NoError(t, err)
+	defer producer.Close()
+	consumer, err := services.NewJetStreamConsumer(url, cfg)
+	require.NoError(t, err)
+	defer consumer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, producer.PublishSync(ctx, "metrics", []byte(`{"value":1}`), nil))
+	ch, err := consumer.Subscribe("metrics")
+	require.NoError(t, err)
+
+	msg := receive(t, ch)
+	assert.Equal(t, uint64(1), msg.NumDelivered())
+	require.NoError(t, msg.Nak(0))
+
+	redelivered := receive(t, ch)
+	assert.Equal(t, `{"value":1}`, string(redelivered.Data()))
+	assert.Equal(t, uint64(2), redelivered.NumDelivered())
+	require.NoError(t, redelivered.Ack())
+}
+
+func TestJetStream_DistinctDurablePerTopic(t *testing.T) {
+	url := natstest.Run(t)
+	cfg := services.JetStreamConfig{
+		Stream:     "INGEST",
+		Subjects:   []string{"a.b", "a_b"},
+		AckTimeout: 5 * time.Second,
+		Durable:    "workers",
+		AckWait:    time.Minute,
+	}
+	producer, err := services.NewJetStreamProducer(url, cfg)
+	require.NoError(t, err)
+	defer producer.Close()
+	consumer, err := services.NewJetStreamConsumer(url, cfg)
+	require.NoError(t, err)
+	defer consumer.Close()
+
+	// Both topics would map to the same durable name without escaping "_".
+	dotted, err := consumer.Subscribe("a.b")
+	require.NoError(t, err)
+	underscored, err := consumer.Subscribe("a_b")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, producer.PublishSync(ctx, "a.b", []byte(`{"value":1}`), nil))
+	require.NoError(t, producer.PublishSync(ctx, "a_b", []byte(`{"value":2}`), nil))
+
+	assert.Equal(t, "a.b", receive(t, dotted).Subject())
+	assert.Equal(t, "a_b", receive(t, underscored).Subject())
+}
+
+func TestStartEmbeddedNATS_RemovesTemporaryStore(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	server, err := services.StartEmbeddedNATS(services.EmbeddedNATSConfig{Host: "127.0.0.1", Port: -1, JetStream: true})
+	require.NoError(t, err)
+	entries, err := os.ReadDir(tmp)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	server.Shutdown()
+	entries, err = os.ReadDir(tmp)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
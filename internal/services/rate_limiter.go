@@ -0,0 +1,245 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimit describes the request and byte budgets of a client. A zero field is
+// unlimited.
+type RateLimit struct {
+	// RequestsPerSecond is the sustained request rate.
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+	// RequestBurst is the number of requests that may be sent at once; it
+	// defaults to RequestsPerSecond rounded up.
+	RequestBurst int `mapstructure:"request_burst"`
+	// BytesPerSecond is the sustained request body throughput.
+	BytesPerSecond float64 `mapstructure:"bytes_per_second"`
+	// ByteBurst is the number of body bytes that may be sent at once; it
+	// defaults to BytesPerSecond rounded up.
+	ByteBurst int `mapstructure:"byte_burst"`
+	// DailyRequests is the number of requests allowed per UTC day.
+	DailyRequests int64 `mapstructure:"daily_requests"`
+	// DailyBytes is the number of body bytes allowed per UTC day.
+	DailyBytes int64 `mapstructure:"daily_bytes"`
+}
+
+// RateLimitError tells why a request was limited and when the client may retry.
+type RateLimitError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return e.Reason
+}
+
+// RateLimiter enforces token-bucket rate limits and daily quotas per key, such
+// as a client ID or an IP address.
+type RateLimiter struct {
+	mu       sync.Mutex
+	defaults RateLimit
+	clients  map[string]RateLimit
+	buckets  map[string]*bucket
+	// sweptDay is the day state from earlier days was last dropped.
+	sweptDay time.Time
+}
+
+// bucket holds the limiter state of a single key. Quota counters are reset at
+// the start of every UTC day.
+type bucket struct {
+	requests *rate.Limiter
+	bytes    *rate.Limiter
+	limit    RateLimit
+	// client is the client whose limits apply to the key, if any.
+	client string
+
+	day          time.Time
+	dailyCount   int64
+	dailyBytes   int64
+	lastActivity time.Time
+}
+
+// NewRateLimiter creates a limiter applying the default limits to every key
+// except the clients listed in clients, whose limits replace the defaults
+// entirely. Client IDs are matched case-insensitively, since configuration
+// keys are not case-sensitive.
+func NewRateLimiter(defaults RateLimit, clients map[string]RateLimit) *RateLimiter {
+	l := &RateLimiter{buckets: make(map[string]*bucket)}
+	l.setLimits(defaults, clients)
+	return l
+}
+
+// SetLimits replaces the limits while the limiter is in use. Keys already seen
+// switch to their new limits; their tokens and the requests and bytes already
+// counted against today's quotas are kept.
+func (l *RateLimiter) SetLimits(defaults RateLimit, clients map[string]RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.setLimits(defaults, clients)
+	now := time.Now()
+	for _, b := range l.buckets {
+		b.setLimit(l.limitOf(b.client), now)
+	}
+}
+
+func (l *RateLimiter) setLimits(defaults RateLimit, clients map[string]RateLimit) {
+	l.defaults = defaults
+	l.clients = make(map[string]RateLimit, len(clients))
+	for client, limit := range clients {
+		l.clients[strings.ToLower(client)] = limit
+	}
+}
+
+// limitOf returns the limits of a client, or the defaults if the client has
+// none of its own.
+func (l *RateLimiter) limitOf(client string) RateLimit {
+	if client != "" {
+		if limit, ok := l.clients[strings.ToLower(client)]; ok {
+			return limit
+		}
+	}
+	return l.defaults
+}
+
+// Allow charges one request of n body bytes to the key and returns a
+// *RateLimitError if it exceeds the key's budget. client is the authenticated
+// client ID used to look up per-client limits; it may be empty.
+func (l *RateLimiter) Allow(key, client string, n int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b := l.bucket(key, client, now)
+
+	if b.limit.DailyRequests > 0 && b.dailyCount >= b.limit.DailyRequests {
+		return &RateLimitError{Reason: "daily request quota exceeded", RetryAfter: untilNextDay(now)}
+	}
+	if b.limit.DailyBytes > 0 && b.dailyBytes+n > b.limit.DailyBytes {
+		return &RateLimitError{Reason: "daily byte quota exceeded", RetryAfter: untilNextDay(now)}
+	}
+
+	request := b.requests.ReserveN(now, 1)
+	if delay := request.DelayFrom(now); delay > 0 {
+		request.CancelAt(now)
+		return &RateLimitError{Reason: "request rate limit exceeded", RetryAfter: delay}
+	}
+
+	// A body larger than the burst can never fit in the bucket, so it takes
+	// the whole bucket instead.
+	tokens := int(n)
+	if burst := b.bytes.Burst(); tokens > burst {
+		tokens = burst
+	}
+	if tokens > 0 {
+		body := b.bytes.ReserveN(now, tokens)
+		if delay := body.DelayFrom(now); delay > 0 {
+			body.CancelAt(now)
+			request.CancelAt(now)
+			return &RateLimitError{Reason: "byte rate limit exceeded", RetryAfter: delay}
+		}
+	}
+
+	b.dailyCount++
+	b.dailyBytes += n
+	return nil
+}
+
+// ChargeBytes charges n body bytes to the key after the fact, for requests whose
+// size was unknown when Allow was called. The bytes count against the daily
+// quota and are paid back by the byte rate before further bodies are allowed.
+func (l *RateLimiter) ChargeBytes(key, client string, n int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b := l.bucket(key, client, now)
+	b.dailyBytes += n
+
+	tokens := int(n)
+	if burst := b.bytes.Burst(); tokens > burst {
+		tokens = burst
+	}
+	if tokens > 0 {
+		b.bytes.ReserveN(now, tokens)
+	}
+}
+
+// bucket returns the state of the key, creating it on first use.
+func (l *RateLimiter) bucket(key, client string, now time.Time) *bucket {
+	day := now.UTC().Truncate(24 * time.Hour)
+	if l.sweptDay.Before(day) {
+		l.sweep(day)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		limit := l.limitOf(client)
+		b = &bucket{
+			requests: newTokenBucket(limit.RequestsPerSecond, limit.RequestBurst),
+			bytes:    newTokenBucket(limit.BytesPerSecond, limit.ByteBurst),
+			limit:    limit,
+			client:   client,
+			day:      day,
+		}
+		l.buckets[key] = b
+	}
+
+	if b.day.Before(day) {
+		b.day = day
+		b.dailyCount = 0
+		b.dailyBytes = 0
+	}
+	b.lastActivity = now
+	return b
+}
+
+// sweep drops keys not seen since before the given day. It runs once a day, so
+// the number of keys is bounded by the keys seen in about two days.
+func (l *RateLimiter) sweep(day time.Time) {
+	for key, b := range l.buckets {
+		if b.lastActivity.Before(day) {
+			delete(l.buckets, key)
+		}
+	}
+	l.sweptDay = day
+}
+
+// setLimit applies new limits to the key's token buckets.
+func (b *bucket) setLimit(limit RateLimit, now time.Time) {
+	b.limit = limit
+	setTokenBucket(b.requests, limit.RequestsPerSecond, limit.RequestBurst, now)
+	setTokenBucket(b.bytes, limit.BytesPerSecond, limit.ByteBurst, now)
+}
+
+func newTokenBucket(perSecond float64, burst int) *rate.Limiter {
+	r, burst := tokenBucketRate(perSecond, burst)
+	return rate.NewLimiter(r, burst)
+}
+
+func setTokenBucket(limiter *rate.Limiter, perSecond float64, burst int, now time.Time) {
+	r, burst := tokenBucketRate(perSecond, burst)
+	limiter.SetLimitAt(now, r)
+	limiter.SetBurstAt(now, burst)
+}
+
+// tokenBucketRate returns the rate and burst of a token bucket; a rate of zero
+// or less is unlimited.
+func tokenBucketRate(perSecond float64, burst int) (rate.Limit, int) {
+	if perSecond <= 0 {
+		return rate.Inf, 0
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(perSecond))
+	}
+	return rate.Limit(perSecond), burst
+}
+
+func untilNextDay(now time.Time) time.Duration {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+}
@@ -0,0 +1,91 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+func requireLimited(t *testing.T, err error, reason string) *services.RateLimitError {
+	t.Helper()
+	var limitErr *services.RateLimitError
+	require.True(t, errors.As(err, &limitErr), "expected a RateLimitError, got %v", err)
+	assert.Equal(t, reason, limitErr.Reason)
+	assert.Greater(t, limitErr.RetryAfter, time.Duration(0))
+	return limitErr
+}
+
+func TestRateLimiter_RequestRate(t *testing.T) {
+	limiter := services.NewRateLimiter(services.RateLimit{RequestsPerSecond: 1, RequestBurst: 2}, nil)
+
+	assert.NoError(t, limiter.Allow("ip:10.0.0.1", "", 0))
+	assert.NoError(t, limiter.Allow("ip:10.0.0.1", "", 0))
+	limitErr := requireLimited(t, limiter.Allow("ip:10.0.0.1", "", 0), "request rate limit exceeded")
+	assert.LessOrEqual(t, limitErr.RetryAfter, time.Second)
+
+	// Every key has its own bucket.
+	assert.NoError(t, limiter.Allow("ip:10.0.0.2", "", 0))
+}
+
+func TestRateLimiter_ByteRate(t *testing.T) {
+	limiter := services.NewRateLimiter(services.RateLimit{BytesPerSecond: 100, ByteBurst: 100}, nil)
+
+	assert.NoError(t, limiter.Allow("client:a", "a", 60))
+	requireLimited(t, limiter.Allow("client:a", "a", 60), "byte rate limit exceeded")
+
+	// A body larger than the burst takes the whole bucket instead of being
+	// rejected forever.
+	assert.NoError(t, limiter.Allow("client:b", "b", 1000))
+	requireLimited(t, limiter.Allow("client:b", "b", 1), "byte rate limit exceeded")
+
+	// Bytes charged after the fact must be paid back first.
+	assert.NoError(t, limiter.Allow("client:c", "c", 0))
+	limiter.ChargeBytes("client:c", "c", 100)
+	requireLimited(t, limiter.Allow("client:c", "c", 1), "byte rate limit exceeded")
+}
+
+func TestRateLimiter_DailyQuotas(t *testing.T) {
+	limiter := services.NewRateLimiter(services.RateLimit{DailyRequests: 2, DailyBytes: 100}, nil)
+
+	assert.NoError(t, limiter.Allow("client:a", "a", 10))
+	assert.NoError(t, limiter.Allow("client:a", "a", 10))
+	limitErr := requireLimited(t, limiter.Allow("client:a", "a", 10), "daily request quota exceeded")
+	assert.LessOrEqual(t, limitErr.RetryAfter, 24*time.Hour)
+
+	requireLimited(t, limiter.Allow("client:b", "b", 101), "daily byte quota exceeded")
+}
+
+func TestRateLimiter_PerClientLimits(t *testing.T) {
+	limiter := services.NewRateLimiter(
+		services.RateLimit{RequestsPerSecond: 1, RequestBurst: 1},
+		map[string]services.RateLimit{"bulk-loader": {}},
+	)
+
+	assert.NoError(t, limiter.Allow("client:dashboard", "dashboard", 0))
+	requireLimited(t, limiter.Allow("client:dashboard", "dashboard", 0), "request rate limit exceeded")
+
+	// The client's own limits replace the defaults; zero limits are unlimited.
+	for i := 0; i < 100; i++ {
+		require.NoError(t, limiter.Allow("client:Bulk-Loader", "Bulk-Loader", 1<<20))
+	}
+}
+
+func TestRateLimiter_SetLimits(t *testing.T) {
+	limiter := services.NewRateLimiter(services.RateLimit{RequestsPerSecond: 1, RequestBurst: 1, DailyRequests: 3}, nil)
+
+	assert.NoError(t, limiter.Allow("client:a", "a", 0))
+	requireLimited(t, limiter.Allow("client:a", "a", 0), "request rate limit exceeded")
+
+	// Keys already seen switch to the new limits but keep their daily counts.
+	limiter.SetLimits(services.RateLimit{DailyRequests: 3}, map[string]services.RateLimit{"b": {RequestsPerSecond: 1, RequestBurst: 1}})
+	assert.NoError(t, limiter.Allow("client:a", "a", 0))
+	assert.NoError(t, limiter.Allow("client:a", "a", 0))
+	requireLimited(t, limiter.Allow("client:a", "a", 0), "daily request quota exceeded")
+
+	assert.NoError(t, limiter.Allow("client:b", "b", 0))
+	requireLimited(t, limiter.Allow("client:b", "b", 0), "request rate limit exceeded")
+}
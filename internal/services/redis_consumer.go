@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"play.ground/generic-data-collector/internal/interfaces"
+)
+
+const (
+	// redisReadCount is the maximum number of entries read at once.
+	redisReadCount = 64
+	// redisBlock bounds how long a read waits for new entries.
+	redisBlock = time.Second
+	// redisRetryDelay is the pause after a failed read.
+	redisRetryDelay = time.Second
+)
+
+// RedisConsumer implements the Consumer interface by reading Redis streams.
+// Plain subscriptions read new entries and deliver them at most once, like core
+// NATS. Queue subscriptions read through a consumer group named after the
+// queue: entries stay pending until acknowledged, and entries left pending for
+// ClaimMinIdle are redelivered, so they survive worker crashes.
+type RedisConsumer struct {
+	client *redis.Client
+	cfg    RedisConfig
+	// name identifies the consumer within consumer groups.
+	name string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	subs []*redisSubscription
+}
+
+// redisSubscription is a goroutine reading a stream into a Go channel until
+// its context is canceled.
+type redisSubscription struct {
+	topic  string
+	ch     chan interfaces.Message
+	cancel context.CancelFunc
+}
+
+// NewRedisConsumer creates a consumer connected to the configured server.
+func NewRedisConsumer(cfg RedisConfig) (interfaces.Consumer, error) {
+	client, err := newRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RedisConsumer{
+		client: client,
+		cfg:    cfg,
+		name:   fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// Subscribe reads the entries appended to the stream of the topic from now on.
+func (c *RedisConsumer) Subscribe(topic string) (<-chan interfaces.Message, error) {
+	if err := validateStream(topic); err != nil {
+		return nil, err
+	}
+	return c.start(topic, func(ctx context.Context, ch chan<- interfaces.Message) {
+		c.read(ctx, topic, ch)
+	})
+}
+
+// QueueSubscribe reads the stream of the topic through the consumer group
+// named after the queue, creating the group if needed. Each entry is delivered
+// to only one consumer of the group.
+func (c *RedisConsumer) QueueSubscribe(topic, queue string) (<-chan interfaces.Message, error) {
+	if err := validateStream(topic); err != nil {
+		return nil, err
+	}
+	err := c.client.XGroupCreateMkStream(c.ctx, topic, queue, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, fmt.Errorf("redis: failed to create consumer group %q: %w", queue, err)
+	}
+	return c.start(topic, func(ctx context.Context, ch chan<- interfaces.Message) {
+		c.readGroup(ctx, topic, queue, ch)
+	})
+}
+
+// validateStream rejects topics that are not valid stream names.
+func validateStream(topic string) error {
+	// Streams are looked up by name, so wildcards cannot be supported.
+	if err := ValidateSubject(topic, false); err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	return nil
+}
+
+// start runs read in a goroutine, which closes the returned channel once read
+// returns.
+func (c *RedisConsumer) start(topic string, read func(context.Context, chan<- interfaces.Message)) (<-chan interfaces.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ctx.Err() != nil {
+		return nil, ErrBrokerClosed
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	sub := &redisSubscription{
+		topic:  topic,
+		ch:     make(chan interfaces.Message, redisReadCount),
+		cancel: cancel,
+	}
+	c.subs = append(c.subs, sub)
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer close(sub.ch)
+		read(ctx, sub.ch)
+	}()
+	return sub.ch, nil
+}
+
+// Unsubscribe stops reading the stream of the topic and closes the channels of
+// its subscriptions. Entries of consumer groups that were not acknowledged stay
+// pending.
+func (c *RedisConsumer) Unsubscribe(topic string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	kept := c.subs[:0]
+	for _, sub := range c.subs {
+		if sub.topic == topic {
+			sub.cancel()
+		} else {
+			kept = append(kept, sub)
+		}
+	}
+	c.subs = kept
+	return nil
+}
+
+func (c *RedisConsumer) read(ctx context.Context, stream string, ch chan<- interfaces.Message) {
+	lastID := "$"
+	for {
+		streams, err := c.client.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{stream, lastID},
+			Count:   redisReadCount,
+			Block:   redisBlock,
+		}).Result()
+		if !c.retry(ctx, err) {
+			return
+		}
+		for _, s := range streams {
+			for _, entry := range s.Messages {
+				lastID = entry.ID
+				if !c.send(ctx, ch, newRedisMessage(nil, stream, "", "", entry, 1)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *RedisConsumer) readGroup(ctx context.Context, stream, group string, ch chan<- interfaces.Message) {
+	// Claiming right away picks up the entries left by consumers that are gone.
+	var nextClaim time.Time
+	for {
+		if c.cfg.ClaimMinIdle > 0 && !time.Now().Before(nextClaim) {
+			if !c.claim(ctx, stream, group, ch) {
+				return
+			}
+			nextClaim = time.Now().Add(c.cfg.ClaimMinIdle / 2)
+		}
+
+		streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: c.name,
+			Streams:  []string{stream, ">"},
+			Count:    redisReadCount,
+			Block:    redisBlock,
+		}).Result()
+		if !c.retry(ctx, err) {
+			return
+		}
+		for _, s := range streams {
+			for _, entry := range s.Messages {
+				if !c.send(ctx, ch, newRedisMessage(c.client, stream, group, c.name, entry, 1)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// claim redelivers the entries of the group that stayed pending for longer
+// than ClaimMinIdle. It returns false once the subscription is closed.
+func (c *RedisConsumer) claim(ctx context.Context, stream, group string, ch chan<- interfaces.Message) bool {
+	start := "0-0"
+	for {
+		entries, next, err := c.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    group,
+			Consumer: c.name,
+			MinIdle:  c.cfg.ClaimMinIdle,
+			Start:    start,
+			Count:    redisReadCount,
+		}).Result()
+		if err != nil {
+			return c.retry(ctx, err)
+		}
+		for _, entry := range entries {
+			if !c.send(ctx, ch, newRedisMessage(c.client, stream, group, c.name, entry, c.deliveries(ctx, stream, group, entry.ID))) {
+				return false
+			}
+		}
+		if next == "0-0" || next == "" {
+			return true
+		}
+		start = next
+	}
+}
+
+// deliveries returns how many times the claimed entry has been delivered to
+// the group. Claimed entries were delivered before, so the count is at least 2
+// even if it cannot be read.
+func (c *RedisConsumer) deliveries(ctx context.Context, stream, group, id string) uint64 {
+	pending, err := c.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  group,
+		Start:  id,
+		End:    id,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 || pending[0].RetryCount < 2 {
+		return 2
+	}
+	return uint64(pending[0].RetryCount)
+}
+
+// retry reports whether reading should go on after err, pausing after
+// failures. It returns false once the subscription is closed.
+func (c *RedisConsumer) retry(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err == nil || errors.Is(err, redis.Nil) {
+		return true
+	}
+	select {
+	case <-time.After(redisRetryDelay):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (c *RedisConsumer) send(ctx context.Context, ch chan<- interfaces.Message, msg interfaces.Message) bool {
+	select {
+	case ch <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Pending returns the number of messages buffered in the subscription channels.
+func (c *RedisConsumer) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pending := 0
+	for _, sub := range c.subs {
+		pending += len(sub.ch)
+	}
+	return pending
+}
+
+// Health reports an error unless the server answers a PING.
+func (c *RedisConsumer) Health(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+// Close stops reading, closes the subscription channels and closes the
+// connections to the server. Entries of consumer groups that were not
+// acknowledged stay pending and are redelivered.
+func (c *RedisConsumer) Close() error {
+	c.mu.Lock()
+	c.cancel()
+	c.mu.Unlock()
+
+	c.wg.Wait()
+	return c.client.Close()
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"play.ground/generic-data-collector/internal/interfaces"
+)
+
+// redisAckTimeout bounds the acknowledgement calls to the server.
+const redisAckTimeout = 5 * time.Second
+
+// RedisMessage wraps a Redis stream entry. Entries read through a consumer
+// group are acknowledged with XACK; the acknowledgement methods of other
+// entries are no-ops.
+type RedisMessage struct {
+	// client is nil for entries read outside a consumer group.
+	client   *redis.Client
+	stream   string
+	group    string
+	consumer string
+	id       string
+
+	data      []byte
+	header    interfaces.Header
+	delivered uint64
+}
+
+func newRedisMessage(client *redis.Client, stream, group, consumer string, entry redis.XMessage, delivered uint64) *RedisMessage {
+	msg := &RedisMessage{
+		client:    client,
+		stream:    stream,
+		group:     group,
+		consumer:  consumer,
+		id:        entry.ID,
+		delivered: delivered,
+	}
+	if data, ok := entry.Values[redisDataField].(string); ok {
+		msg.data = []byte(data)
+	}
+	if header, ok := entry.Values[redisHeaderField].(string); ok {
+		// A malformed header is dropped rather than losing the record.
+		_ = json.Unmarshal([]byte(header), &msg.header)
+	}
+	return msg
+}
+
+func (m *RedisMessage) Data() []byte { return m.data }
+
+func (m *RedisMessage) Subject() string { return m.stream }
+
+func (m *RedisMessage) Header() interfaces.Header { return m.header }
+
+// Ack removes the entry from the pending entries of the group.
+func (m *RedisMessage) Ack() error {
+	if m.client == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisAckTimeout)
+	defer cancel()
+	return m.client.XAck(ctx, m.stream, m.group, m.id).Err()
+}
+
+// Nak leaves the entry pending. Redis has no delayed redelivery, so the entry
+// is redelivered once it has been idle for ClaimMinIdle, whatever the delay.
+// Since InProgress restarts the idle time, an entry whose batch was retried is
+// redelivered ClaimMinIdle after the last attempt.
+func (m *RedisMessage) Nak(time.Duration) error { return nil }
+
+// Term acknowledges the entry, so that it is never redelivered.
+func (m *RedisMessage) Term() error { return m.Ack() }
+
+// InProgress resets the idle time of the entry, postponing its redelivery by
+// ClaimMinIdle.
+func (m *RedisMessage) InProgress() error {
+	if m.client == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisAckTimeout)
+	defer cancel()
+	return m.client.XClaimJustID(ctx, &redis.XClaimArgs{
+		Stream:   m.stream,
+		Group:    m.group,
+		Consumer: m.consumer,
+		Messages: []string{m.id},
+	}).Err()
+}
+
+func (m *RedisMessage) NumDelivered() uint64 { return m.delivered }
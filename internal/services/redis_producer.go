@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"play.ground/generic-data-collector/internal/interfaces"
+)
+
+const (
+	// redisConnectTimeout bounds the initial PING to the server.
+	redisConnectTimeout = 5 * time.Second
+
+	// Fields of the stream entries.
+	redisDataField   = "data"
+	redisHeaderField = "header"
+)
+
+// RedisConfig holds the settings of the Redis Streams broker. Every subject is
+// stored in the stream of the same name.
+type RedisConfig struct {
+	// URL is the redis:// or rediss:// URL of the server.
+	URL string
+	// MaxLen caps the length of each stream, approximately; zero means unlimited.
+	MaxLen int64
+	// ClaimMinIdle is how long a message delivered to a consumer group may stay
+	// unacknowledged before it is redelivered. Redis cannot delay redeliveries,
+	// so naked messages are redelivered after ClaimMinIdle too, whatever the
+	// delay asked for; marking a message in progress restarts its idle time.
+	ClaimMinIdle time.Duration
+}
+
+// ValidateRedisURL reports whether url is a Redis URL the broker can connect
+// to. The error does not quote the URL, which may hold credentials.
+func ValidateRedisURL(url string) error {
+	if _, err := redis.ParseURL(url); err != nil {
+		return errors.New("invalid Redis URL")
+	}
+	return nil
+}
+
+// newRedisClient connects to the server and checks that it answers.
+func newRedisClient(cfg RedisConfig) (*redis.Client, error) {
+	opts, err := redis.ParseURL(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisConnectTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return client, nil
+}
+
+// RedisProducer implements the Producer interface by appending messages to
+// Redis streams.
+type RedisProducer struct {
+	client *redis.Client
+	cfg    RedisConfig
+}
+
+// NewRedisProducer creates a producer connected to the configured server.
+func NewRedisProducer(cfg RedisConfig) (interfaces.Producer, error) {
+	client, err := newRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisProducer{client: client, cfg: cfg}, nil
+}
+
+// Publish appends the message to the stream of the topic. Redis confirms every
+// append, so Publish waits for the confirmation like PublishSync.
+func (p *RedisProducer) Publish(topic string, message []byte, header interfaces.Header) error {
+	return p.PublishSync(context.Background(), topic, message, header)
+}
+
+// PublishSync appends the message to the stream of the topic and returns once
+// Redis has stored it or the context is done.
+func (p *RedisProducer) PublishSync(ctx context.Context, topic string, message []byte, header interfaces.Header) error {
+	values := []any{redisDataField, message}
+	if len(header) > 0 {
+		encoded, err := json.Marshal(header)
+		if err != nil {
+			return err
+		}
+		values = append(values, redisHeaderField, encoded)
+	}
+	return p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: topic,
+		MaxLen: p.cfg.MaxLen,
+		Approx: p.cfg.MaxLen > 0,
+		Values: values,
+	}).Err()
+}
+
+// Health reports an error unless the server answers a PING.
+func (p *RedisProducer) Health(ctx context.Context) error {
+	return p.client.Ping(ctx).Err()
+}
+
+// Close closes the connections to the server.
+func (p *RedisProducer) Close() error {
+	return p.client.Close()
+}
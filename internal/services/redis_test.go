@@ -0,0 +1,76 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"play.ground/generic-data-collector/internal/interfaces"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+func newRedisBroker(t *testing.T, claimMinIdle time.Duration) (*miniredis.Miniredis, interfaces.Producer, interfaces.Consumer) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	cfg := services.RedisConfig{URL: "redis://" + server.Addr(), ClaimMinIdle: claimMinIdle}
+
+	producer, err := services.NewRedisProducer(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { producer.Close() })
+	consumer, err := services.NewRedisConsumer(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { consumer.Close() })
+	return server, producer, consumer
+}
+
+func TestRedis_Subscribe(t *testing.T) {
+	_, producer, consumer := newRedisBroker(t, time.Minute)
+	ch, err := consumer.Subscribe("metrics")
+	require.NoError(t, err)
+	// Let the first read start, so it waits for new entries.
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, producer.Publish("metrics", []byte(`{"value":1}`), interfaces.Header{"Client-Id": "c1"}))
+	msg := receive(t, ch)
+	assert.Equal(t, "metrics", msg.Subject())
+	assert.Equal(t, `{"value":1}`, string(msg.Data()))
+	assert.Equal(t, interfaces.Header{"Client-Id": "c1"}, msg.Header())
+	assert.NoError(t, msg.Ack())
+
+	_, err = consumer.Subscribe("ingest.>")
+	assert.Error(t, err, "streams cannot be matched by wildcards")
+
+	// The pending read may block for up to a second before the channel closes.
+	require.NoError(t, consumer.Unsubscribe("metrics"))
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok)
+	case <-time.After(3 * time.Second):
+		t.Fatal("channel not closed")
+	}
+}
+
+func TestRedis_QueueSubscribeRedeliversUnacknowledged(t *testing.T) {
+	server, producer, consumer := newRedisBroker(t, 50*time.Millisecond)
+	ch, err := consumer.QueueSubscribe("metrics", "workers")
+	require.NoError(t, err)
+
+	require.NoError(t, producer.PublishSync(context.Background(), "metrics", []byte(`{"value":1}`), nil))
+	msg := receive(t, ch)
+	assert.Equal(t, uint64(1), msg.NumDelivered())
+	require.NoError(t, msg.Nak(0))
+
+	// Once idle for ClaimMinIdle, the entry is delivered again.
+	server.FastForward(time.Second)
+	redelivered := receive(t, ch)
+	assert.Equal(t, `{"value":1}`, string(redelivered.Data()))
+	assert.Equal(t, uint64(2), redelivered.NumDelivered())
+	require.NoError(t, redelivered.Ack())
+
+	require.NoError(t, producer.Publish("metrics", []byte(`{"value":2}`), nil))
+	assert.Equal(t, `{"value":2}`, string(receive(t, ch).Data()))
+	assert.NoError(t, consumer.Health(context.Background()))
+}
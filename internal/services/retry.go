@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes how failed operations are retried: exponential backoff
+// with full jitter, bounded by a number of attempts and a total elapsed time.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// InitialInterval is the backoff ceiling before the second attempt.
+	InitialInterval time.Duration
+	// MaxInterval caps the backoff ceiling of any single wait.
+	MaxInterval time.Duration
+	// Multiplier grows the backoff ceiling after each attempt.
+	Multiplier float64
+	// MaxElapsedTime stops retrying once this much time has passed since the
+	// first attempt; zero means no limit.
+	MaxElapsedTime time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used when none is configured.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     3,
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     10 * time.Second,
+		Multiplier:      2,
+		MaxElapsedTime:  time.Minute,
+	}
+}
+
+// PermanentError marks an error that retrying cannot fix.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err so that a RetryPolicy does not retry it.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsRetryable reports whether err may succeed when the operation is retried.
+func IsRetryable(err error) bool {
+	var permanent *PermanentError
+	return err != nil && !errors.As(err, &permanent)
+}
+
+// RetryError is returned by RetryPolicy.Do when the operation did not succeed.
+type RetryError struct {
+	// Attempts is the number of times the operation was run.
+	Attempts int
+	// FirstFailure and LastFailure are the times the first and last attempts failed.
+	FirstFailure time.Time
+	LastFailure  time.Time
+	// Err is the error returned by the last attempt.
+	Err error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("failed after %d attempt(s): %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Do runs fn until it succeeds, returns a permanent error, the attempts or the
+// elapsed time are exhausted, or ctx is done. fn always runs at least once;
+// ctx only interrupts the waits between attempts. On failure the returned
+// error is a *RetryError.
+func (p RetryPolicy) Do(ctx context.Context, fn func(attempt int) error) error {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	start := time.Now()
+	var retryErr *RetryError
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+		if err == nil {
+			return nil
+		}
+
+		now := time.Now()
+		if retryErr == nil {
+			retryErr = &RetryError{FirstFailure: now}
+		}
+		retryErr.Attempts = attempt
+		retryErr.LastFailure = now
+		retryErr.Err = err
+
+		if !IsRetryable(err) || attempt >= maxAttempts {
+			return retryErr
+		}
+
+		wait := p.backoff(attempt)
+		if p.MaxElapsedTime > 0 && now.Add(wait).Sub(start) > p.MaxElapsedTime {
+			return retryErr
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return retryErr
+		}
+	}
+}
+
+// backoff returns a random wait in [0, ceiling) where the ceiling grows
+// exponentially with each attempt ("full jitter").
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	ceiling := float64(p.InitialInterval) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxInterval > 0 && ceiling > float64(p.MaxInterval) {
+		ceiling = float64(p.MaxInterval)
+	}
+	if ceiling > math.MaxInt64 {
+		ceiling = math.MaxInt64
+	}
+	if ceiling < 1 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
@@ -0,0 +1,102 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+func fastRetryPolicy(maxAttempts int) services.RetryPolicy {
+	return services.RetryPolicy{
+		MaxAttempts:     maxAttempts,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     5 * time.Millisecond,
+		Multiplier:      2,
+	}
+}
+
+func TestRetryPolicy_SucceedsAfterRetries(t *testing.T) {
+	calls := 0
+	err := fastRetryPolicy(5).Do(context.Background(), func(attempt int) error {
+		calls++
+		assert.Equal(t, calls, attempt)
+		if attempt < 3 {
+			return errors.New("temporary")
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryPolicy_ExhaustsAttempts(t *testing.T) {
+	cause := errors.New("temporary")
+	err := fastRetryPolicy(3).Do(context.Background(), func(int) error {
+		return cause
+	})
+
+	var retryErr *services.RetryError
+	require.ErrorAs(t, err, &retryErr)
+	assert.Equal(t, 3, retryErr.Attempts)
+	assert.ErrorIs(t, err, cause)
+	assert.False(t, retryErr.LastFailure.Before(retryErr.FirstFailure))
+}
+
+func TestRetryPolicy_StopsOnPermanentError(t *testing.T) {
+	calls := 0
+	err := fastRetryPolicy(5).Do(context.Background(), func(int) error {
+		calls++
+		return services.Permanent(errors.New("bad request"))
+	})
+
+	assert.Error(t, err)
+	assert.False(t, services.IsRetryable(err))
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryPolicy_StopsWhenContextDone(t *testing.T) {
+	policy := services.RetryPolicy{
+		MaxAttempts:     10,
+		InitialInterval: time.Hour,
+		MaxInterval:     time.Hour,
+		Multiplier:      2,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	calls := 0
+	err := policy.Do(ctx, func(int) error {
+		calls++
+		return errors.New("temporary")
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestRetryPolicy_MaxElapsedTime(t *testing.T) {
+	policy := services.RetryPolicy{
+		MaxAttempts:     1000,
+		InitialInterval: 5 * time.Millisecond,
+		MaxInterval:     5 * time.Millisecond,
+		Multiplier:      1,
+		MaxElapsedTime:  30 * time.Millisecond,
+	}
+
+	start := time.Now()
+	err := policy.Do(context.Background(), func(int) error {
+		return errors.New("temporary")
+	})
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+}
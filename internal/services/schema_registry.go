@@ -0,0 +1,155 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Violation describes a single JSON Schema violation.
+type Violation struct {
+	// Path is the JSON pointer of the offending value within the record.
+	Path string `json:"path"`
+	// Message describes why the value is invalid.
+	Message string `json:"message"`
+}
+
+// SchemaRegistry holds the JSON Schemas used to validate records per topic.
+// Schemas are loaded from "<topic>.json" files in a directory and default to
+// draft 2020-12 unless the document declares another "$schema". A nil
+// *SchemaRegistry accepts every record.
+type SchemaRegistry struct {
+	dir     string
+	schemas map[string]*jsonschema.Schema
+	mu      sync.RWMutex
+}
+
+// NewSchemaRegistry loads all schemas from dir.
+func NewSchemaRegistry(dir string) (*SchemaRegistry, error) {
+	r := &SchemaRegistry{dir: dir}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload recompiles every schema in the directory. The new set replaces the
+// current one only if all schemas compile, so a bad edit never leaves the
+// registry half-updated.
+func (r *SchemaRegistry) Reload() error {
+	r.mu.RLock()
+	dir := r.dir
+	r.mu.RUnlock()
+	return r.ReloadFrom(dir)
+}
+
+// ReloadFrom is like Reload, but loads the schemas from another directory,
+// which later reloads use as well.
+func (r *SchemaRegistry) ReloadFrom(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+
+	schemas := make(map[string]*jsonschema.Schema, len(paths))
+	var errs []error
+	for _, path := range paths {
+		topic := strings.TrimSuffix(filepath.Base(path), ".json")
+		schema, err := compileSchemaFile(compiler, path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("schema for topic %q: %w", topic, err))
+			continue
+		}
+		schemas[topic] = schema
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	r.mu.Lock()
+	r.dir = dir
+	r.schemas = schemas
+	r.mu.Unlock()
+	return nil
+}
+
+func compileSchemaFile(compiler *jsonschema.Compiler, path string) (*jsonschema.Schema, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(abs)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	url := "file://" + filepath.ToSlash(abs)
+	if err := compiler.AddResource(url, file); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(url)
+}
+
+// Topics returns the topics that have a schema, in alphabetical order.
+func (r *SchemaRegistry) Topics() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	topics := make([]string, 0, len(r.schemas))
+	for topic := range r.schemas {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// Validate checks a decoded JSON record against the topic's schema and returns
+// every violation found. Topics without a schema accept any record.
+func (r *SchemaRegistry) Validate(topic string, record interface{}) []Violation {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	schema, ok := r.schemas[topic]
+	r.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	err := schema.Validate(record)
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return []Violation{{Path: "", Message: err.Error()}}
+	}
+	return collectViolations(validationErr, nil)
+}
+
+// collectViolations flattens the error tree into its leaves, which name the
+// keywords that actually failed.
+func collectViolations(err *jsonschema.ValidationError, violations []Violation) []Violation {
+	if len(err.Causes) == 0 {
+		return append(violations, Violation{Path: err.InstanceLocation, Message: err.Message})
+	}
+	for _, cause := range err.Causes {
+		violations = collectViolations(cause, violations)
+	}
+	return violations
+}
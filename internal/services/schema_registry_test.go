@@ -0,0 +1,63 @@
+package services_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+const eventSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["type"],
+	"properties": {
+		"type": {"type": "string"},
+		"count": {"type": "integer", "minimum": 0}
+	}
+}`
+
+func writeSchema(t *testing.T, dir, topic, schema string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, topic+".json"), []byte(schema), 0o644))
+}
+
+func TestSchemaRegistry_Validate(t *testing.T) {
+	dir := t.TempDir()
+	writeSchema(t, dir, "events", eventSchema)
+
+	schemas, err := services.NewSchemaRegistry(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"events"}, schemas.Topics())
+
+	assert.Empty(t, schemas.Validate("events", map[string]interface{}{"type": "login", "count": 1.0}))
+
+	violations := schemas.Validate("events", map[string]interface{}{"count": -1.0})
+	require.Len(t, violations, 2)
+	paths := []string{violations[0].Path, violations[1].Path}
+	assert.ElementsMatch(t, []string{"", "/count"}, paths)
+
+	// Topics without a schema accept any record.
+	assert.Empty(t, schemas.Validate("metrics", map[string]interface{}{}))
+}
+
+func TestSchemaRegistry_Reload(t *testing.T) {
+	dir := t.TempDir()
+	writeSchema(t, dir, "events", eventSchema)
+
+	schemas, err := services.NewSchemaRegistry(dir)
+	require.NoError(t, err)
+
+	// A broken schema is rejected and the previous schemas stay active.
+	writeSchema(t, dir, "logs", `{"type": 42}`)
+	assert.Error(t, schemas.Reload())
+	assert.Equal(t, []string{"events"}, schemas.Topics())
+
+	writeSchema(t, dir, "logs", `{"type": "object", "required": ["message"]}`)
+	require.NoError(t, schemas.Reload())
+	assert.Equal(t, []string{"events", "logs"}, schemas.Topics())
+	assert.NotEmpty(t, schemas.Validate("logs", map[string]interface{}{}))
+}
@@ -0,0 +1,70 @@
+package services_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+func TestFileSink_AppendsNDJSON(t *testing.T) {
+	// The directory of the file is created as well.
+	path := filepath.Join(t.TempDir(), "data", "records.ndjson")
+
+	sink, err := services.NewFileSink(path)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, sink.Write(ctx, [][]byte{[]byte(`{"value":1}`), []byte(`{"value":2}`)}))
+	require.NoError(t, sink.Write(ctx, [][]byte{[]byte(`{"value":3}`)}))
+	assert.NoError(t, sink.Health(ctx))
+	require.NoError(t, sink.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"value\":1}\n{\"value\":2}\n{\"value\":3}\n", string(content))
+
+	assert.Error(t, sink.Write(ctx, [][]byte{[]byte(`{}`)}), "writes after Close must fail")
+}
+
+func TestHTTPSink_PostsBatch(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink, err := services.NewHTTPSink(server.URL, "", 0)
+	require.NoError(t, err)
+	defer sink.Close()
+
+	err = sink.Write(context.Background(), [][]byte{[]byte(`{"value":1}`), []byte(`{"value":2}`)})
+	require.NoError(t, err)
+	assert.Equal(t, "{\"value\":1}\n{\"value\":2}\n", received)
+}
+
+func TestHTTPSink_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	sink, err := services.NewHTTPSink(server.URL, server.URL, 0)
+	require.NoError(t, err)
+	defer sink.Close()
+
+	err = sink.Write(context.Background(), [][]byte{[]byte(`{}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "503")
+	assert.Error(t, sink.Health(context.Background()))
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"play.ground/generic-data-collector/internal/interfaces"
+)
+
+// subjectMessage reports the topic a message was subscribed on when the broker
+// does not provide its subject.
+type subjectMessage struct {
+	interfaces.Message
+	subject string
+}
+
+func (m subjectMessage) Subject() string {
+	return m.subject
+}
+
+// errSubscriptionsClosed is returned when changing the topics of closed
+// Subscriptions.
+var errSubscriptionsClosed = errors.New("subscriptions closed")
+
+// Subscriptions merges the subscriptions to a set of topics into a single
+// channel. The topics can be changed while the channel is read.
+type Subscriptions struct {
+	ctx        context.Context
+	consumer   interfaces.Consumer
+	queueGroup string
+	merged     chan interfaces.Message
+
+	mu     sync.Mutex
+	topics []string
+	// active is the number of subscriptions being forwarded.
+	active int
+	closed bool
+}
+
+// SubscribeAll subscribes to every topic, joining queueGroup if it is not empty,
+// and merges the subscriptions into a single channel. The channel is closed once
+// all subscriptions are closed, or once ctx is done, which lets the reader stop
+// reading early.
+func SubscribeAll(ctx context.Context, consumer interfaces.Consumer, queueGroup string, topics []string) (<-chan interfaces.Message, error) {
+	subs, err := NewSubscriptions(ctx, consumer, queueGroup, topics)
+	if err != nil {
+		return nil, err
+	}
+	return subs.Messages(), nil
+}
+
+// NewSubscriptions is like SubscribeAll, but returns the Subscriptions, whose
+// topics can be changed afterwards.
+func NewSubscriptions(ctx context.Context, consumer interfaces.Consumer, queueGroup string, topics []string) (*Subscriptions, error) {
+	s := &Subscriptions{
+		ctx:        ctx,
+		consumer:   consumer,
+		queueGroup: queueGroup,
+		merged:     make(chan interfaces.Message),
+	}
+	if err := s.SetTopics(topics); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Messages returns the channel merging the subscriptions.
+func (s *Subscriptions) Messages() <-chan interfaces.Message {
+	return s.merged
+}
+
+// Topics returns the topics subscribed to.
+func (s *Subscriptions) Topics() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.topics...)
+}
+
+// SetTopics subscribes to the topics that are not subscribed to yet and then
+// unsubscribes from the topics that are no longer listed. The messages already
+// received on those topics are still forwarded. If a subscription fails, the
+// topics are left unchanged.
+func (s *Subscriptions) SetTopics(topics []string) error {
+	if len(topics) == 0 {
+		return errors.New("at least one topic is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errSubscriptionsClosed
+	}
+
+	current := make(map[string]bool, len(s.topics))
+	for _, topic := range s.topics {
+		current[topic] = true
+	}
+	next := make(map[string]bool, len(topics))
+	var added []string
+	for _, topic := range topics {
+		if !next[topic] && !current[topic] {
+			added = append(added, topic)
+		}
+		next[topic] = true
+	}
+
+	channels := make([]<-chan interfaces.Message, 0, len(added))
+	for i, topic := range added {
+		ch, err := s.subscribe(topic)
+		if err != nil {
+			for _, subscribed := range added[:i] {
+				_ = s.consumer.Unsubscribe(subscribed)
+			}
+			return err
+		}
+		channels = append(channels, ch)
+	}
+	// The forwarders can't stop before the lock is released, so the merged
+	// channel stays open while the topics change.
+	for i, ch := range channels {
+		s.active++
+		go s.forward(added[i], ch)
+	}
+
+	var errs []error
+	kept := make([]string, 0, len(next))
+	for _, topic := range s.topics {
+		if next[topic] {
+			kept = append(kept, topic)
+		} else if err := s.consumer.Unsubscribe(topic); err != nil {
+			errs = append(errs, fmt.Errorf("failed to unsubscribe from %q: %w", topic, err))
+		}
+	}
+	s.topics = append(kept, added...)
+	return errors.Join(errs...)
+}
+
+// Unsubscribe unsubscribes from every topic. The channel is closed once the
+// messages already received have been read.
+func (s *Subscriptions) Unsubscribe() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, topic := range s.topics {
+		if err := s.consumer.Unsubscribe(topic); err != nil {
+			errs = append(errs, fmt.Errorf("failed to unsubscribe from %q: %w", topic, err))
+		}
+	}
+	s.topics = nil
+	return errors.Join(errs...)
+}
+
+func (s *Subscriptions) subscribe(topic string) (<-chan interfaces.Message, error) {
+	if s.queueGroup == "" {
+		return s.consumer.Subscribe(topic)
+	}
+	return s.consumer.QueueSubscribe(topic, s.queueGroup)
+}
+
+// forward copies the messages of a subscription to the merged channel until the
+// subscription is closed or ctx is done.
+func (s *Subscriptions) forward(topic string, ch <-chan interfaces.Message) {
+	defer s.stopped()
+	for {
+		var msg interfaces.Message
+		var ok bool
+		select {
+		case msg, ok = <-ch:
+			if !ok {
+				return
+			}
+		case <-s.ctx.Done():
+			return
+		}
+		if msg.Subject() == "" {
+			msg = subjectMessage{Message: msg, subject: topic}
+		}
+		select {
+		case s.merged <- msg:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// stopped closes the merged channel once the last subscription is closed.
+func (s *Subscriptions) stopped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.active--
+	if s.active == 0 {
+		s.closed = true
+		close(s.merged)
+	}
+}
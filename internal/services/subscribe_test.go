@@ -0,0 +1,72 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+func TestSubscribeAll_MergesTopics(t *testing.T) {
+	broker, err := services.NewMemoryBroker(services.MemoryBrokerConfig{})
+	require.NoError(t, err)
+	defer broker.Close()
+
+	ch, err := services.SubscribeAll(context.Background(), broker, "", []string{"metrics", "ingest.>"})
+	require.NoError(t, err)
+	require.NoError(t, broker.Publish("metrics", []byte(`{"a":1}`), nil))
+	require.NoError(t, broker.Publish("ingest.events", []byte(`{"a":2}`), nil))
+
+	subjects := []string{receive(t, ch).Subject(), receive(t, ch).Subject()}
+	assert.ElementsMatch(t, []string{"metrics", "ingest.events"}, subjects)
+}
+
+func TestSubscribeAll_StopsWhenContextIsDone(t *testing.T) {
+	broker, err := services.NewMemoryBroker(services.MemoryBrokerConfig{})
+	require.NoError(t, err)
+	defer broker.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := services.SubscribeAll(ctx, broker, "", []string{"metrics"})
+	require.NoError(t, err)
+
+	// A message nobody reads must not keep the forwarder blocked.
+	require.NoError(t, broker.Publish("metrics", []byte(`{}`), nil))
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	require.Eventually(t, func() bool {
+		select {
+		case _, ok := <-ch:
+			return !ok
+		default:
+			return false
+		}
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestSubscriptions_SetTopics(t *testing.T) {
+	broker, err := services.NewMemoryBroker(services.MemoryBrokerConfig{})
+	require.NoError(t, err)
+	defer broker.Close()
+
+	subs, err := services.NewSubscriptions(context.Background(), broker, "", []string{"metrics", "logs"})
+	require.NoError(t, err)
+	require.NoError(t, subs.SetTopics([]string{"logs", "events"}))
+	assert.Equal(t, []string{"logs", "events"}, subs.Topics())
+
+	require.NoError(t, broker.Publish("metrics", []byte(`{}`), nil))
+	require.NoError(t, broker.Publish("events", []byte(`{}`), nil))
+	assert.Equal(t, "events", receive(t, subs.Messages()).Subject())
+
+	// The merged channel stays open while a topic remains.
+	require.NoError(t, broker.Publish("logs", []byte(`{}`), nil))
+	assert.Equal(t, "logs", receive(t, subs.Messages()).Subject())
+
+	assert.Error(t, subs.SetTopics(nil))
+	assert.Error(t, subs.SetTopics([]string{"metrics", "bad..subject"}))
+	assert.Equal(t, []string{"logs", "events"}, subs.Topics())
+}
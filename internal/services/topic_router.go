@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// topicNamePattern restricts API topic names to URL-safe identifiers.
+var topicNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+var (
+	// ErrInvalidTopic is returned for topic names that are not valid identifiers.
+	ErrInvalidTopic = errors.New("invalid topic name")
+	// ErrUnknownTopic is returned for topics that are not in the allow-list.
+	ErrUnknownTopic = errors.New("unknown topic")
+)
+
+// ValidateSubject checks that subject is a valid NATS subject: non-empty
+// dot-separated tokens without whitespace. Wildcards ("*" as a whole token and
+// ">" as the last token) are accepted only when allowWildcards is set.
+func ValidateSubject(subject string, allowWildcards bool) error {
+	if subject == "" {
+		return errors.New("subject is empty")
+	}
+
+	tokens := strings.Split(subject, ".")
+	for i, token := range tokens {
+		if token == "" {
+			return fmt.Errorf("subject %q has an empty token", subject)
+		}
+		if strings.ContainsAny(token, " \t\r\n") {
+			return fmt.Errorf("subject %q contains whitespace", subject)
+		}
+
+		switch {
+		case token == "*" || (token == ">" && i == len(tokens)-1):
+			if !allowWildcards {
+				return fmt.Errorf("subject %q must not contain wildcards", subject)
+			}
+		case strings.ContainsAny(token, "*>"):
+			return fmt.Errorf("subject %q has an invalid wildcard", subject)
+		}
+	}
+	return nil
+}
+
+// TopicRouter maps the topics accepted by the API to broker subjects. Only
+// topics in its allow-list can be resolved.
+type TopicRouter struct {
+	mu       sync.RWMutex
+	subjects map[string]string
+}
+
+// NewTopicRouter creates a router from a map of API topic names to subjects.
+// An empty subject maps the topic to a subject of the same name.
+func NewTopicRouter(routes map[string]string) (*TopicRouter, error) {
+	subjects, err := parseRoutes(routes)
+	if err != nil {
+		return nil, err
+	}
+	return &TopicRouter{subjects: subjects}, nil
+}
+
+// SetRoutes replaces the allow-list while the router is in use. The current
+// routes are kept if any of the new ones is invalid.
+func (r *TopicRouter) SetRoutes(routes map[string]string) error {
+	subjects, err := parseRoutes(routes)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.subjects = subjects
+	r.mu.Unlock()
+	return nil
+}
+
+func parseRoutes(routes map[string]string) (map[string]string, error) {
+	subjects := make(map[string]string, len(routes))
+	var errs []error
+	for topic, subject := range routes {
+		if !topicNamePattern.MatchString(topic) {
+			errs = append(errs, fmt.Errorf("%w: %q", ErrInvalidTopic, topic))
+			continue
+		}
+		if subject == "" {
+			subject = topic
+		}
+		if err := ValidateSubject(subject, false); err != nil {
+			errs = append(errs, fmt.Errorf("topic %q: %w", topic, err))
+			continue
+		}
+		subjects[topic] = subject
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return subjects, nil
+}
+
+// Resolve returns the subject for an API topic.
+func (r *TopicRouter) Resolve(topic string) (string, error) {
+	if !topicNamePattern.MatchString(topic) {
+		return "", ErrInvalidTopic
+	}
+	r.mu.RLock()
+	subject, ok := r.subjects[topic]
+	r.mu.RUnlock()
+	if !ok {
+		return "", ErrUnknownTopic
+	}
+	return subject, nil
+}
+
+// Topics returns the allowed API topics in alphabetical order.
+func (r *TopicRouter) Topics() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	topics := make([]string, 0, len(r.subjects))
+	for topic := range r.subjects {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
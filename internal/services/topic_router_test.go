@@ -0,0 +1,89 @@
+package services_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+func TestValidateSubject(t *testing.T) {
+	tests := []struct {
+		subject        string
+		allowWildcards bool
+		valid          bool
+	}{
+		{"metrics", false, true},
+		{"ingest.events", false, true},
+		{"ingest.*", false, false},
+		{"ingest.*", true, true},
+		{"ingest.>", true, true},
+		{"ingest.>.events", true, false},
+		{"ingest.ev*", true, false},
+		{"ingest..events", false, false},
+		{".metrics", false, false},
+		{"metrics.", false, false},
+		{"my metrics", false, false},
+		{"", true, false},
+	}
+
+	for _, tt := range tests {
+		err := services.ValidateSubject(tt.subject, tt.allowWildcards)
+		if tt.valid {
+			assert.NoError(t, err, tt.subject)
+		} else {
+			assert.Error(t, err, tt.subject)
+		}
+	}
+}
+
+func TestTopicRouter(t *testing.T) {
+	router, err := services.NewTopicRouter(map[string]string{
+		"metrics": "",
+		"events":  "ingest.events",
+	})
+	require.NoError(t, err)
+
+	subject, err := router.Resolve("metrics")
+	require.NoError(t, err)
+	assert.Equal(t, "metrics", subject)
+
+	subject, err = router.Resolve("events")
+	require.NoError(t, err)
+	assert.Equal(t, "ingest.events", subject)
+
+	_, err = router.Resolve("logs")
+	assert.ErrorIs(t, err, services.ErrUnknownTopic)
+
+	_, err = router.Resolve("Ingest.Events")
+	assert.ErrorIs(t, err, services.ErrInvalidTopic)
+
+	assert.Equal(t, []string{"events", "metrics"}, router.Topics())
+}
+
+func TestTopicRouter_RejectsInvalidRoutes(t *testing.T) {
+	_, err := services.NewTopicRouter(map[string]string{
+		"events":   "ingest.*",
+		"bad name": "bad",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "wildcards")
+	assert.ErrorIs(t, err, services.ErrInvalidTopic)
+}
+
+func TestTopicRouter_SetRoutes(t *testing.T) {
+	router, err := services.NewTopicRouter(map[string]string{"metrics": ""})
+	require.NoError(t, err)
+
+	require.NoError(t, router.SetRoutes(map[string]string{"events": "ingest.events"}))
+	_, err = router.Resolve("metrics")
+	assert.ErrorIs(t, err, services.ErrUnknownTopic)
+	subject, err := router.Resolve("events")
+	require.NoError(t, err)
+	assert.Equal(t, "ingest.events", subject)
+
+	// Invalid routes leave the current ones in place.
+	require.Error(t, router.SetRoutes(map[string]string{"events": "ingest.>"}))
+	assert.Equal(t, []string{"events"}, router.Topics())
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"play.ground/generic-data-collector/internal/interfaces"
+)
+
+const (
+	TracingExporterNone   = "none"
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+	TracingExporterFile   = "file"
+
+	// tracerName identifies the spans created by this service.
+	tracerName = "play.ground/generic-data-collector"
+)
+
+// tracePropagator carries trace context in message headers in the W3C format.
+var tracePropagator = propagation.TraceContext{}
+
+// TracingConfig selects where spans are exported.
+type TracingConfig struct {
+	// ServiceName is reported as the service.name of every span.
+	ServiceName string
+	// Exporter is one of "none", "otlp", "stdout" or "file".
+	Exporter string
+	// Endpoint is the host:port of the OTLP/HTTP collector; empty uses the
+	// OTEL_EXPORTER_OTLP_ENDPOINT environment variable or localhost:4318.
+	Endpoint string
+	// Insecure disables TLS when talking to the collector.
+	Insecure bool
+	// FilePath is the file the "file" exporter appends spans to.
+	FilePath string
+	// SampleRatio is the fraction of new traces that are recorded, between 0
+	// and 1; traces started upstream follow the upstream decision.
+	SampleRatio float64
+}
+
+// Tracer returns the tracer used for the spans of this service.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// NewTracerProvider creates a tracer provider exporting spans as configured and
+// installs it as the global provider. It returns nil with the "none" exporter,
+// leaving tracing disabled. The caller shuts the provider down to flush the
+// remaining spans.
+func NewTracerProvider(ctx context.Context, cfg TracingConfig) (*sdktrace.TracerProvider, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "", TracingExporterNone:
+		return nil, nil
+	case TracingExporterOTLP:
+		opts := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case TracingExporterFile:
+		exporter, err = newFileExporter(cfg.FilePath)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q: expected %q, %q, %q or %q",
+			cfg.Exporter, TracingExporterNone, TracingExporterOTLP, TracingExporterStdout, TracingExporterFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider, nil
+}
+
+// fileExporter appends spans as JSON to a file, which it closes on shutdown.
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+func newFileExporter(path string) (*fileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &fileExporter{SpanExporter: exporter, file: file}, nil
+}
+
+// Shutdown flushes the exporter and closes the file.
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.file.Close())
+}
+
+// ExtractHTTPTraceContext returns a copy of ctx carrying the trace context sent
+// by the client in the traceparent header, if any.
+func ExtractHTTPTraceContext(ctx context.Context, header http.Header) context.Context {
+	return tracePropagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// InjectTraceContext adds the trace context of ctx to the message header,
+// creating the header if needed.
+func InjectTraceContext(ctx context.Context, header interfaces.Header) interfaces.Header {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return header
+	}
+	if header == nil {
+		header = make(interfaces.Header)
+	}
+	tracePropagator.Inject(ctx, propagation.MapCarrier(header))
+	return header
+}
+
+// ExtractTraceContext returns the span context carried in the message header;
+// it is invalid if the message carries none.
+func ExtractTraceContext(msg interfaces.Message) trace.SpanContext {
+	header := msg.Header()
+	if len(header) == 0 {
+		return trace.SpanContext{}
+	}
+	ctx := tracePropagator.Extract(context.Background(), propagation.MapCarrier(header))
+	return trace.SpanContextFromContext(ctx)
+}
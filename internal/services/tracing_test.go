@@ -0,0 +1,107 @@
+package services_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"play.ground/generic-data-collector/internal/interfaces"
+	"play.ground/generic-data-collector/internal/services"
+)
+
+// recordSpans installs a tracer provider recording every span for the
+// duration of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestBatchProcessor_LinksFlushToRecordSpans(t *testing.T) {
+	recorder := recordSpans(t)
+
+	// Without a span there is no trace context to carry.
+	assert.Nil(t, services.InjectTraceContext(context.Background(), nil))
+
+	ctx, publishSpan := services.Tracer().Start(context.Background(), "publish metrics")
+	header := services.InjectTraceContext(ctx, interfaces.Header{interfaces.HeaderClientID: "dashboard"})
+	assert.Equal(t, "dashboard", header[interfaces.HeaderClientID])
+	require.Contains(t, header, "traceparent")
+	publishSpan.End()
+
+	mockConsumer := services.NewMockConsumer()
+	processor := services.NewBatchProcessor(mockConsumer, services.NewMockSink(),
+		services.WithBatchLimits(services.BatchLimits{MaxRecords: 2}),
+	)
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- processor.Start(runCtx, "metrics")
+	}()
+
+	traced := services.NewMockMessageWithHeader([]byte(`{"value": 1}`), header)
+	untraced := services.NewMockMessage([]byte(`{"value": 2}`))
+	mockConsumer.SendMessage(traced)
+	mockConsumer.SendMessage(untraced)
+	require.Eventually(t, untraced.Acked, time.Second, 5*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	var flush sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "flush batch" {
+			flush = span
+		}
+	}
+	require.NotNil(t, flush, "flush span not recorded")
+	require.Len(t, flush.Links(), 1)
+	assert.Equal(t, publishSpan.SpanContext().TraceID(), flush.Links()[0].SpanContext.TraceID())
+	assert.Equal(t, publishSpan.SpanContext().SpanID(), flush.Links()[0].SpanContext.SpanID())
+	assert.NotEqual(t, publishSpan.SpanContext().TraceID(), flush.SpanContext().TraceID())
+}
+
+func TestNewTracerProvider_FileExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	path := filepath.Join(t.TempDir(), "spans.json")
+	provider, err := services.NewTracerProvider(context.Background(), services.TracingConfig{
+		Exporter:    services.TracingExporterFile,
+		FilePath:    path,
+		SampleRatio: 1,
+	})
+	require.NoError(t, err)
+	_, span := services.Tracer().Start(context.Background(), "write")
+	span.End()
+	require.NoError(t, provider.Shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"write"`)
+}
+
+func TestNewTracerProvider_ZeroSampleRatio(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	provider, err := services.NewTracerProvider(context.Background(), services.TracingConfig{
+		Exporter: services.TracingExporterStdout,
+	})
+	require.NoError(t, err)
+	defer provider.Shutdown(context.Background())
+
+	_, span := services.Tracer().Start(context.Background(), "dropped")
+	defer span.End()
+	assert.False(t, span.IsRecording())
+}